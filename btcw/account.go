@@ -0,0 +1,247 @@
+package btcw
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// AddressType selects the BIP44 style purpose an account is derived under.
+type AddressType int
+
+const (
+	AddressTypeLegacy       AddressType = iota // BIP44, P2PKH
+	AddressTypeNestedSegwit                    // BIP49, P2SH-P2WPKH
+	AddressTypeNativeSegwit                    // BIP84, P2WPKH
+	AddressTypeTaproot                         // BIP86, P2TR
+)
+
+// AllAddressTypes lists every address type in the order wallets usually scan them.
+var AllAddressTypes = []AddressType{AddressTypeLegacy, AddressTypeNestedSegwit, AddressTypeNativeSegwit, AddressTypeTaproot}
+
+const (
+	ExternalChain uint32 = 0 // receive addresses
+	InternalChain uint32 = 1 // change addresses
+)
+
+// DefaultGapLimit is the number of consecutive unused addresses after which
+// BIP44 discovery stops scanning a chain.
+const DefaultGapLimit = 20
+
+func (t AddressType) Purpose() uint32 {
+	switch t {
+	case AddressTypeLegacy:
+		return 44
+	case AddressTypeNestedSegwit:
+		return 49
+	case AddressTypeNativeSegwit:
+		return 84
+	case AddressTypeTaproot:
+		return 86
+	}
+	return 0
+}
+
+func (t AddressType) String() string {
+	switch t {
+	case AddressTypeLegacy:
+		return "p2pkh"
+	case AddressTypeNestedSegwit:
+		return "p2sh-p2wpkh"
+	case AddressTypeNativeSegwit:
+		return "p2wpkh"
+	case AddressTypeTaproot:
+		return "p2tr"
+	}
+	return fmt.Sprintf("AddressType(%d)", int(t))
+}
+
+// AddressFromPubKey encodes a compressed public key as an address of type t.
+func (t AddressType) AddressFromPubKey(serializedPubKey []byte, net *chaincfg.Params) (string, error) {
+	switch t {
+	case AddressTypeLegacy:
+		return GetLegacyAddressFromPubKeyBytes(serializedPubKey, net)
+	case AddressTypeNestedSegwit:
+		return GetNestedSegwitAddressFromPubKeyBytes(serializedPubKey, net)
+	case AddressTypeNativeSegwit:
+		return GetSegwitAddressFromPubKeyBytes(serializedPubKey, net)
+	case AddressTypeTaproot:
+		return GetTaprootAddressFromPubKeyBytes(serializedPubKey, net)
+	}
+	return "", fmt.Errorf("unknown address type %d", int(t))
+}
+
+// Account is a BIP44/49/84/86 account, m/purpose'/coin_type'/account'.
+// Key may be private or, for watch-only accounts, public.
+type Account struct {
+	Type  AddressType
+	Index uint32
+	Net   *chaincfg.Params
+	Key   *HDKey
+}
+
+// DeriveAccount derives the account for addressType from a master key. The
+// coin type is 0 on mainnet and 1 on the test networks.
+func DeriveAccount(master *HDKey, addressType AddressType, index uint32) (*Account, error) {
+	if addressType.Purpose() == 0 {
+		return nil, fmt.Errorf("unknown address type %d", int(addressType))
+	}
+	net := master.Net()
+	path := fmt.Sprintf("m/%d'/%d'/%d'", addressType.Purpose(), net.HDCoinType, index)
+	key, err := master.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Account{addressType, index, net, key}, nil
+}
+
+// NewWatchOnlyAccount creates an account from an account level xpub/tpub.
+func NewWatchOnlyAccount(accountKey *HDKey, addressType AddressType, index uint32) (*Account, error) {
+	if accountKey.IsPrivate() {
+		var err error
+		accountKey, err = accountKey.Neuter()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Account{addressType, index, accountKey.Net(), accountKey}, nil
+}
+
+// Path returns the derivation path of the account.
+func (a *Account) Path() string {
+	return fmt.Sprintf("m/%d'/%d'/%d'", a.Type.Purpose(), a.Net.HDCoinType, a.Index)
+}
+
+// AddressPath returns the derivation path of an address in the account.
+func (a *Account) AddressPath(chain, index uint32) string {
+	return fmt.Sprintf("%s/%d/%d", a.Path(), chain, index)
+}
+
+func (a *Account) Address(chain, index uint32) (string, error) {
+	key, err := a.Key.Derive(fmt.Sprintf("%d/%d", chain, index))
+	if err != nil {
+		return "", err
+	}
+	pubKey, err := key.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	return a.Type.AddressFromPubKey(pubKey, a.Net)
+}
+
+func (a *Account) Wallet(chain, index uint32) (*Wallet, error) {
+	return a.Key.DeriveWallet(fmt.Sprintf("%d/%d", chain, index))
+}
+
+// BalanceFunc looks up the balance and transaction count of an address.
+type BalanceFunc func(ctx context.Context, address string) (*GetBalanceResponse, error)
+
+// NewBalanceFunc returns a BalanceFunc backed by GetBalance.
+func NewBalanceFunc(net *chaincfg.Params) BalanceFunc {
+	return func(ctx context.Context, address string) (*GetBalanceResponse, error) {
+		return GetBalance(ctx, net, address)
+	}
+}
+
+type DiscoveredAddress struct {
+	Address string
+	Path    string
+	Chain   uint32
+	Index   uint32
+	Balance int
+	NTx     int
+}
+
+// AccountDiscovery is the result of scanning an account's receive and change chains.
+type AccountDiscovery struct {
+	Account            *Account
+	UsedAddresses      []DiscoveredAddress
+	NextReceiveAddress string
+	NextReceiveIndex   uint32
+	NextChangeIndex    uint32
+	TotalBalance       int
+}
+
+// IsUsed reports whether any address of the account has a transaction.
+func (d *AccountDiscovery) IsUsed() bool {
+	return len(d.UsedAddresses) > 0
+}
+
+// DiscoverAccount scans the receive and change chains of account until
+// gapLimit consecutive addresses without transactions are found.
+func DiscoverAccount(ctx context.Context, account *Account, gapLimit int, getBalance BalanceFunc) (*AccountDiscovery, error) {
+	if gapLimit <= 0 {
+		gapLimit = DefaultGapLimit
+	}
+
+	discovery := &AccountDiscovery{Account: account}
+	for _, chain := range []uint32{ExternalChain, InternalChain} {
+		var nextIndex uint32
+		gap := 0
+		for index := uint32(0); gap < gapLimit; index++ {
+			address, err := account.Address(chain, index)
+			if err != nil {
+				return nil, err
+			}
+			balance, err := getBalance(ctx, address)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get balance of %s: %w", address, err)
+			}
+
+			// 잔고가 0 이어도 거래 내역이 있으면 사용된 주소로 본다.
+			if balance.NTx == 0 && balance.FinalNTx == 0 {
+				gap++
+				continue
+			}
+			gap = 0
+			nextIndex = index + 1
+			discovery.UsedAddresses = append(discovery.UsedAddresses, DiscoveredAddress{
+				Address: address,
+				Path:    account.AddressPath(chain, index),
+				Chain:   chain,
+				Index:   index,
+				Balance: balance.FinalBalance,
+				NTx:     balance.FinalNTx,
+			})
+			discovery.TotalBalance += balance.FinalBalance
+		}
+
+		if chain == ExternalChain {
+			discovery.NextReceiveIndex = nextIndex
+		} else {
+			discovery.NextChangeIndex = nextIndex
+		}
+	}
+
+	nextReceiveAddress, err := account.Address(ExternalChain, discovery.NextReceiveIndex)
+	if err != nil {
+		return nil, err
+	}
+	discovery.NextReceiveAddress = nextReceiveAddress
+	return discovery, nil
+}
+
+// DiscoverAccounts recovers every used account of every address type from a
+// master key. Accounts are scanned in order and scanning of an address type
+// stops at the first account without transactions, as described in BIP44.
+func DiscoverAccounts(ctx context.Context, master *HDKey, gapLimit int, getBalance BalanceFunc) ([]*AccountDiscovery, error) {
+	var discoveries []*AccountDiscovery
+	for _, addressType := range AllAddressTypes {
+		for index := uint32(0); index < HardenedKeyStart; index++ {
+			account, err := DeriveAccount(master, addressType, index)
+			if err != nil {
+				return nil, err
+			}
+			discovery, err := DiscoverAccount(ctx, account, gapLimit, getBalance)
+			if err != nil {
+				return nil, err
+			}
+			if !discovery.IsUsed() {
+				break
+			}
+			discoveries = append(discoveries, discovery)
+		}
+	}
+	return discoveries, nil
+}
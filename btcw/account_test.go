@@ -0,0 +1,138 @@
+package btcw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestDeriveAccountAddresses(t *testing.T) {
+	// BIP44, BIP49, BIP84, BIP86 문서의 테스트 벡터
+	tests := []struct {
+		net         *chaincfg.Params
+		addressType AddressType
+		chain       uint32
+		path        string
+		address     string
+	}{
+		{&chaincfg.MainNetParams, AddressTypeLegacy, ExternalChain, "m/44'/0'/0'/0/0", "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"},
+		{&chaincfg.TestNet3Params, AddressTypeNestedSegwit, ExternalChain, "m/49'/1'/0'/0/0", "2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2"},
+		{&chaincfg.MainNetParams, AddressTypeNativeSegwit, ExternalChain, "m/84'/0'/0'/0/0", "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{&chaincfg.MainNetParams, AddressTypeNativeSegwit, InternalChain, "m/84'/0'/0'/1/0", "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el"},
+		{&chaincfg.MainNetParams, AddressTypeTaproot, ExternalChain, "m/86'/0'/0'/0/0", "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+	}
+
+	for _, test := range tests {
+		master, err := NewMasterKeyFromMnemonic(testMnemonic, "", test.net)
+		if err != nil {
+			t.Fatal(err)
+		}
+		account, err := DeriveAccount(master, test.addressType, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path := account.AddressPath(test.chain, 0); path != test.path {
+			t.Errorf("%s: got path %s", test.addressType, path)
+		}
+		address, err := account.Address(test.chain, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address != test.address {
+			t.Errorf("%s: got address %s, expected %s", test.addressType, address, test.address)
+		}
+
+		// xpub 만 가진 watch-only 계정도 같은 주소를 만들어야 한다.
+		watchOnly, err := NewWatchOnlyAccount(account.Key, test.addressType, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		watchOnlyAddress, _ := watchOnly.Address(test.chain, 0)
+		if watchOnlyAddress != test.address {
+			t.Errorf("%s: watch-only address %s", test.addressType, watchOnlyAddress)
+		}
+	}
+}
+
+func TestDiscoverAccount(t *testing.T) {
+	net := &chaincfg.MainNetParams
+	master, _ := NewMasterKeyFromMnemonic(testMnemonic, "", net)
+	account, _ := DeriveAccount(master, AddressTypeNativeSegwit, 0)
+
+	receive0, _ := account.Address(ExternalChain, 0)
+	receive4, _ := account.Address(ExternalChain, 4)
+	receive9, _ := account.Address(ExternalChain, 9) // gap limit 5 를 넘어서 찾지 못해야 함
+	change1, _ := account.Address(InternalChain, 1)
+
+	balances := map[string]*GetBalanceResponse{
+		receive0: {FinalBalance: 0, NTx: 2, FinalNTx: 2},
+		receive4: {FinalBalance: 1500, NTx: 1, FinalNTx: 1},
+		receive9: {FinalBalance: 9999, NTx: 1, FinalNTx: 1},
+		change1:  {FinalBalance: 700, NTx: 0, FinalNTx: 1},
+	}
+	var lookups int
+	getBalance := func(ctx context.Context, address string) (*GetBalanceResponse, error) {
+		lookups++
+		if balance, ok := balances[address]; ok {
+			return balance, nil
+		}
+		return &GetBalanceResponse{Address: address}, nil
+	}
+
+	discovery, err := DiscoverAccount(context.Background(), account, 4, getBalance)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(discovery.UsedAddresses) != 3 {
+		t.Fatalf("expected 3 used addresses, got %+v", discovery.UsedAddresses)
+	}
+	if discovery.TotalBalance != 2200 {
+		t.Errorf("expected total balance 2200, got %d", discovery.TotalBalance)
+	}
+	if discovery.NextReceiveIndex != 5 || discovery.NextChangeIndex != 2 {
+		t.Errorf("unexpected next indexes %d %d", discovery.NextReceiveIndex, discovery.NextChangeIndex)
+	}
+	receive5, _ := account.Address(ExternalChain, 5)
+	if discovery.NextReceiveAddress != receive5 {
+		t.Errorf("unexpected next receive address %s", discovery.NextReceiveAddress)
+	}
+	// receive: 0..8 (9 개), change: 0..5 (6 개)
+	if lookups != 15 {
+		t.Errorf("expected 15 balance lookups, got %d", lookups)
+	}
+}
+
+func TestDiscoverAccounts(t *testing.T) {
+	net := &chaincfg.TestNet3Params
+	master, _ := NewMasterKeyFromMnemonic(testMnemonic, "", net)
+	legacy, _ := DeriveAccount(master, AddressTypeLegacy, 0)
+	taproot1, _ := DeriveAccount(master, AddressTypeTaproot, 1)
+	legacyAddress, _ := legacy.Address(ExternalChain, 0)
+	taprootAddress, _ := taproot1.Address(ExternalChain, 2)
+
+	// taproot account 0 이 비어 있으므로 account 1 은 찾지 않는다.
+	getBalance := func(ctx context.Context, address string) (*GetBalanceResponse, error) {
+		switch address {
+		case legacyAddress:
+			return &GetBalanceResponse{FinalBalance: 100, NTx: 1, FinalNTx: 1}, nil
+		case taprootAddress:
+			return &GetBalanceResponse{FinalBalance: 200, NTx: 1, FinalNTx: 1}, nil
+		}
+		return &GetBalanceResponse{}, nil
+	}
+
+	discoveries, err := DiscoverAccounts(context.Background(), master, 3, getBalance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discoveries) != 1 {
+		t.Fatalf("expected 1 used account, got %d", len(discoveries))
+	}
+	if discoveries[0].Account.Type != AddressTypeLegacy || discoveries[0].TotalBalance != 100 {
+		t.Errorf("unexpected discovery %+v", discoveries[0])
+	}
+}
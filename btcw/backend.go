@@ -0,0 +1,165 @@
+package btcw
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ChainBackend is a provider of chain data, e.g. BlockCypher or a bitcoind
+// node. Implementations must be safe for concurrent use.
+type ChainBackend interface {
+	// ListUTXOs returns the unspent outputs of address. PKScript may be nil.
+	ListUTXOs(ctx context.Context, address string) ([]*UTXO, error)
+	GetBalance(ctx context.Context, address string) (*GetBalanceResponse, error)
+	// EstimateFee returns a fee rate in sat/vB for confirmation within targetBlocks.
+	EstimateFee(ctx context.Context, targetBlocks int) (float64, error)
+	// Broadcast sends a signed transaction and returns its txid.
+	Broadcast(ctx context.Context, rawTxHex string) (string, error)
+	GetTransaction(ctx context.Context, txid string) (*wire.MsgTx, error)
+	TipHeight(ctx context.Context) (int64, error)
+}
+
+// HTTPStatusError is returned by the HTTP backends for a non-2xx response.
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("http status error. status code: %d body: %s", e.StatusCode, e.Body)
+}
+
+// DefaultFeeTargetBlocks is the confirmation target used for fee estimates.
+const DefaultFeeTargetBlocks = 6
+
+// Client builds, signs and sends transactions using a ChainBackend.
+type Client struct {
+	Backend ChainBackend
+	Net     *chaincfg.Params
+	// FeeTargetBlocks is the confirmation target for fee estimates.
+	FeeTargetBlocks int
+	// Rand is used by coin selection, a time seeded source if nil. It is not
+	// safe for concurrent use, set it in tests to get the same selection.
+	Rand *rand.Rand
+	// Reservations keeps concurrent transfers from selecting the same UTXOs if
+	// it is set. Reservations last ReservationTTL, DefaultReservationTTL if 0.
+	Reservations   ReservationStore
+	ReservationTTL time.Duration
+}
+
+func NewClient(backend ChainBackend, net *chaincfg.Params) *Client {
+	return &Client{Backend: backend, Net: net, FeeTargetBlocks: DefaultFeeTargetBlocks}
+}
+
+// DefaultClient is used by the package level functions such as
+// CreateTransferTransaction and TransferCoin. If it is nil it is created with
+// NewClientFromEnv on first use, so it follows BTCW_NETWORK and the backend
+// environment variables and talks to BlockCypher testnet3 if none is set.
+var DefaultClient *Client
+
+var defaultClientMu sync.Mutex
+
+// defaultClient returns DefaultClient, creating it from the environment.
+func defaultClient() (*Client, error) {
+	defaultClientMu.Lock()
+	defer defaultClientMu.Unlock()
+	if DefaultClient == nil {
+		client, err := NewClientFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the default client: %w", err)
+		}
+		DefaultClient = client
+	}
+	return DefaultClient, nil
+}
+
+// ListUTXOs returns the UTXOs of address with PKScript filled in.
+func (c *Client) ListUTXOs(ctx context.Context, address string) ([]*UTXO, error) {
+	utxos, err := c.Backend.ListUTXOs(ctx, address)
+	if err != nil {
+		return nil, wrapBackendError(err)
+	}
+
+	var pkScript []byte
+	for _, utxo := range utxos {
+		if utxo.PKScript != nil {
+			continue
+		}
+		if pkScript == nil {
+			pkScript, err = payToAddressScript(address, c.Net)
+			if err != nil {
+				return nil, err
+			}
+		}
+		utxo.PKScript = pkScript
+	}
+	return utxos, nil
+}
+
+func (c *Client) GetBalance(ctx context.Context, address string) (*GetBalanceResponse, error) {
+	balance, err := c.Backend.GetBalance(ctx, address)
+	return balance, wrapBackendError(err)
+}
+
+// BalanceFunc returns a BalanceFunc for DiscoverAccount backed by the client.
+func (c *Client) BalanceFunc() BalanceFunc {
+	return c.GetBalance
+}
+
+// GetCurrentFeeRate gets the current fee rate in sat/vB, at least
+// MinRelayFeeRate.
+func (c *Client) GetCurrentFeeRate(ctx context.Context) (float64, error) {
+	targetBlocks := c.FeeTargetBlocks
+	if targetBlocks <= 0 {
+		targetBlocks = DefaultFeeTargetBlocks
+	}
+	feeRate, err := c.Backend.EstimateFee(ctx, targetBlocks)
+	if err != nil {
+		return 0, wrapBackendError(err)
+	}
+	return math.Max(feeRate, MinRelayFeeRate), nil
+}
+
+// btcPerKBToSatPerVByte converts a BTC/kvB fee rate to sat/vB, rounded to
+// 0.001 sat/vB so that float errors are not rounded up when fees are
+// computed.
+func btcPerKBToSatPerVByte(feeRate float64) float64 {
+	return math.Round(feeRate*btcutil.SatoshiPerBitcoin) / 1000
+}
+
+func (c *Client) SendRawTransaction(ctx context.Context, signedHex string) (string, error) {
+	txid, err := c.Backend.Broadcast(ctx, signedHex)
+	return txid, wrapBackendError(err)
+}
+
+func (c *Client) GetTransaction(ctx context.Context, txid string) (*wire.MsgTx, error) {
+	tx, err := c.Backend.GetTransaction(ctx, txid)
+	return tx, wrapBackendError(err)
+}
+
+func (c *Client) TipHeight(ctx context.Context) (int64, error) {
+	height, err := c.Backend.TipHeight(ctx)
+	return height, wrapBackendError(err)
+}
+
+// payToAddressScript returns the output script of address on net. Errors
+// match ErrInvalidAddress.
+func payToAddressScript(address string, net *chaincfg.Params) ([]byte, error) {
+	decoded, err := btcutil.DecodeAddress(address, net)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidAddress, address, err)
+	}
+	if !decoded.IsForNet(net) {
+		return nil, fmt.Errorf("%w %q: not for network %s", ErrInvalidAddress, address, net.Name)
+	}
+	return txscript.PayToAddrScript(decoded)
+}
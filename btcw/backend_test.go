@@ -0,0 +1,310 @@
+package btcw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// fakeBackend is an in-memory ChainBackend for tests.
+type fakeBackend struct {
+	utxos     map[string][]*UTXO
+	feeRate   float64
+	tipHeight int64
+	txs       map[string]*wire.MsgTx
+	broadcast []string
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		utxos:   make(map[string][]*UTXO),
+		feeRate: 1,
+		txs:     make(map[string]*wire.MsgTx),
+	}
+}
+
+func (f *fakeBackend) ListUTXOs(ctx context.Context, address string) ([]*UTXO, error) {
+	return f.utxos[address], nil
+}
+
+func (f *fakeBackend) GetBalance(ctx context.Context, address string) (*GetBalanceResponse, error) {
+	balance := int(sumUTXOs(f.utxos[address]).Int64())
+	nTx := len(f.utxos[address])
+	return &GetBalanceResponse{
+		Address:      address,
+		Balance:      balance,
+		FinalBalance: balance,
+		NTx:          nTx,
+		FinalNTx:     nTx,
+	}, nil
+}
+
+func (f *fakeBackend) EstimateFee(ctx context.Context, targetBlocks int) (float64, error) {
+	return f.feeRate, nil
+}
+
+func (f *fakeBackend) Broadcast(ctx context.Context, rawTxHex string) (string, error) {
+	tx, err := decodeTxHex(rawTxHex)
+	if err != nil {
+		return "", err
+	}
+	txid := tx.TxHash().String()
+	f.txs[txid] = tx
+	f.broadcast = append(f.broadcast, rawTxHex)
+	return txid, nil
+}
+
+func (f *fakeBackend) GetTransaction(ctx context.Context, txid string) (*wire.MsgTx, error) {
+	tx, ok := f.txs[txid]
+	if !ok {
+		return nil, fmt.Errorf("transaction %s not found", txid)
+	}
+	return tx, nil
+}
+
+func (f *fakeBackend) TipHeight(ctx context.Context) (int64, error) {
+	return f.tipHeight, nil
+}
+
+func TestClientCreateTransferTransaction(t *testing.T) {
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	fromAddress := wallet.GetSegwitAddress(net)
+	toAddress := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
+
+	// backend 가 PKScript 를 주지 않아도 client 가 주소로부터 채운다.
+	backend := newFakeBackend()
+	backend.feeRate = 14.2
+	backend.utxos[fromAddress] = []*UTXO{newTestUTXO(0, 5000, nil), newTestUTXO(1, 50000, nil)}
+	client := NewClient(backend, net)
+	client.Rand = rand.New(rand.NewSource(1))
+
+	signedHex, err := client.CreateTransferTransaction(context.Background(), fromAddress, toAddress, wallet.PrivateKeyToBytes(), 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := decodeTxHex(signedHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.TxIn) != 1 || tx.TxIn[0].PreviousOutPoint.Index != 1 {
+		t.Fatalf("expected the 50000 sat utxo to be spent, got %d inputs", len(tx.TxIn))
+	}
+	if len(tx.TxOut) != 2 || tx.TxOut[0].Value != 10000 {
+		t.Fatalf("unexpected outputs %+v", tx.TxOut)
+	}
+	// 14.2 sat/vB 로 output 1 개짜리 기본 42 vB, P2WPKH input 68 vB, 잔돈 output 31 vB.
+	// 각각 올림한다.
+	if fee := 50000 - tx.TxOut[0].Value - tx.TxOut[1].Value; fee != 597+966+441 {
+		t.Errorf("unexpected fee %d", fee)
+	}
+	verifyTransaction(t, tx, backend.utxos[fromAddress][1:])
+
+	txHash, err := client.TransferCoin(context.Background(), fromAddress, toAddress, wallet.PrivateKeyToBytes(), 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.broadcast) != 1 || txHash != tx.TxHash().String() {
+		t.Errorf("unexpected broadcast %s", txHash)
+	}
+	if _, err := client.GetTransaction(context.Background(), txHash); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientCreateTransferTransactionWitnessOverhead(t *testing.T) {
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	fromAddress := wallet.GetLegacyAddress(BITCOIN_TESTNET_VERSION)
+	toAddress := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
+
+	// 같은 키의 P2PKH 와 P2WPKH UTXO.
+	legacy := newTestUTXO(0, 50000, addressScript(t, fromAddress, net))
+	segwit := newTestUTXO(1, 3000, addressScript(t, wallet.GetSegwitAddress(net), net))
+	backend := newFakeBackend()
+	backend.feeRate = 10
+	backend.utxos[fromAddress] = []*UTXO{legacy, segwit}
+	client := NewClient(backend, net)
+
+	tests := []struct {
+		name string
+		opts TransferOptions
+		// fee 는 기본 41 vB, P2PKH input 148 vB, P2WPKH input 68 vB, 잔돈 output 34 vB 에서.
+		fee int64
+	}{
+		// segwit input 을 쓰지 않으면 marker, flag 를 내지 않는다.
+		{"legacy only", TransferOptions{Exclude: []wire.OutPoint{testOutpoint(t, segwit)}}, 10 * (41 + 148 + 34)},
+		// 섞이면 marker, flag 와 P2PKH input 의 빈 witness 를 낸다. 0.75 vB 를 올림한다.
+		{"mixed", TransferOptions{Include: []wire.OutPoint{testOutpoint(t, legacy), testOutpoint(t, segwit)}}, 10 * (41 + 1 + 148 + 68 + 34)},
+	}
+	for _, test := range tests {
+		signedHex, err := client.CreateTransferTransactionWithOptions(context.Background(), fromAddress, toAddress, wallet.PrivateKeyToBytes(), 10000, test.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		tx, _ := decodeTxHex(signedHex)
+		var total int64
+		for _, in := range tx.TxIn {
+			total += backend.utxos[fromAddress][in.PreviousOutPoint.Index].Amount.Int64()
+		}
+		fee := total - sumTxOut(tx)
+		if fee != test.fee || fee < 10*txVSize(tx) {
+			t.Errorf("%s: fee %d for %d vB, expected %d", test.name, fee, txVSize(tx), test.fee)
+		}
+	}
+}
+
+func TestClientListUTXOsWrongNetwork(t *testing.T) {
+	backend := newFakeBackend()
+	mainnetAddress := "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"
+	backend.utxos[mainnetAddress] = []*UTXO{newTestUTXO(0, 5000, nil)}
+
+	client := NewClient(backend, &chaincfg.TestNet3Params)
+	if _, err := client.ListUTXOs(context.Background(), mainnetAddress); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("listing utxos of a mainnet address on testnet should fail with ErrInvalidAddress, got %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	fromAddress := wallet.GetSegwitAddress(net)
+	toAddress := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
+
+	backend := &flakyBackend{fakeBackend: newFakeBackend()}
+	backend.utxos[fromAddress] = []*UTXO{newTestUTXO(0, 5000, nil)}
+	client := NewClient(backend, net)
+
+	_, err := client.CreateTransferTransaction(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 10000)
+	var insufficient *InsufficientFundsError
+	if !errors.Is(err, ErrInsufficientFunds) || !errors.As(err, &insufficient) || insufficient.Available != 5000 {
+		t.Errorf("expected insufficient funds, got %v", err)
+	}
+
+	// P2WPKH 의 dust 기준은 294 sat
+	_, err = client.CreateTransferTransaction(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 293)
+	var dust *DustError
+	if !errors.Is(err, ErrDust) || !errors.As(err, &dust) || dust.Threshold != 294 {
+		t.Errorf("expected dust error, got %v", err)
+	}
+
+	_, err = client.CreateTransferTransaction(ctx, fromAddress, "mkgMpQSbsJMBffCbVMUk6KryCuFGAtnFoX", wallet.PrivateKeyToBytes(), 1000)
+	if !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("expected invalid address, got %v", err)
+	}
+
+	// dust 가 되는 잔돈은 수수료로 남긴다.
+	signedHex, err := client.CreateTransferTransaction(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 4800)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx, _ := decodeTxHex(signedHex); len(tx.TxOut) != 1 {
+		t.Errorf("dust change should be dropped, got %d outputs", len(tx.TxOut))
+	}
+
+	statusErr := &HTTPStatusError{StatusCode: http.StatusTooManyRequests}
+	backend.setErr(statusErr)
+	_, err = client.TransferCoin(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 1000)
+	var gotStatusErr *HTTPStatusError
+	if !errors.Is(err, ErrBackendUnavailable) || !errors.As(err, &gotStatusErr) || gotStatusErr != statusErr {
+		t.Errorf("expected backend unavailable, got %v", err)
+	}
+}
+
+func TestBlockCypherBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `{"name":"BTC.test3","height":2866877,"high_fee_per_kb":30000,"medium_fee_per_kb":12500,"low_fee_per_kb":4000}`)
+		case "/addrs/miruDdUTqQv9eXMPPwXL73b9iy4gv8KeuH":
+			if r.URL.Query().Get("unspentOnly") != "true" || r.URL.Query().Get("limit") != "2000" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			// 두 page 로 나눠서 준다. 두 번째 page 는 첫 page 의 마지막 높이부터 다시 준다.
+			switch r.URL.Query().Get("before") {
+			case "":
+				fmt.Fprint(w, `{"address":"miruDdUTqQv9eXMPPwXL73b9iy4gv8KeuH","balance":33115,"hasMore":true,"txrefs":[
+					{"tx_hash":"bc26416ce0facd6733b26f5322b21f834ec9206eea9525ffd44e6c1102810fad","block_height":2866858,"tx_output_n":1,"value":17891,"spent":false},
+					{"tx_hash":"ae2db75b9d7bfb9bc3c39e238e1479df3021b206039f67528024ab123990feb4","block_height":2822254,"tx_output_n":0,"value":15224,"spent":true}],
+					"unconfirmed_txrefs":[
+					{"tx_hash":"4ad0ed69e2b2df3fb2ac0dc06d9a1d2b4ba4ef0b70bcfe0b56a9eb4bd1ac41c8","block_height":-1,"tx_input_n":-1,"tx_output_n":0,"value":5000,"spent":false},
+					{"tx_hash":"4ad0ed69e2b2df3fb2ac0dc06d9a1d2b4ba4ef0b70bcfe0b56a9eb4bd1ac41c8","block_height":-1,"tx_input_n":0,"tx_output_n":-1,"value":15224,"spent":false}]}`)
+			case "2822255":
+				fmt.Fprint(w, `{"address":"miruDdUTqQv9eXMPPwXL73b9iy4gv8KeuH","hasMore":false,"txrefs":[
+					{"tx_hash":"ae2db75b9d7bfb9bc3c39e238e1479df3021b206039f67528024ab123990feb4","block_height":2822254,"tx_output_n":0,"value":15224,"spent":true},
+					{"tx_hash":"ae2db75b9d7bfb9bc3c39e238e1479df3021b206039f67528024ab123990feb4","block_height":2822254,"tx_output_n":2,"value":3000,"spent":false},
+					{"tx_hash":"0b6b3a4f9b1e1f8a3b7e4c2d5a6f7e8d9c0b1a2f3e4d5c6b7a8f9e0d1c2b3a4f","block_height":2800000,"tx_output_n":0,"value":7000,"spent":false}]}`)
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		case "/addrs/mkHS9ne12qx9pS9VojpwU5xtRd4T7X7ZUt":
+			// 다음 page 가 같은 높이의 txref 만 다시 준다.
+			fmt.Fprint(w, `{"address":"mkHS9ne12qx9pS9VojpwU5xtRd4T7X7ZUt","hasMore":true,"txrefs":[
+				{"tx_hash":"bc26416ce0facd6733b26f5322b21f834ec9206eea9525ffd44e6c1102810fad","block_height":2866858,"tx_output_n":0,"value":1000,"spent":false}]}`)
+		case "/txs/push":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid transaction"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend := &BlockCypherBackend{BaseURL: server.URL, Token: "secret"}
+	ctx := context.Background()
+
+	utxos, err := backend.ListUTXOs(ctx, "miruDdUTqQv9eXMPPwXL73b9iy4gv8KeuH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 미확인 output 과 두 번째 page 의 output 도 있어야 하고, input 과 중복은 빠진다.
+	if len(utxos) != 4 || utxos[0].BlockHeight != 0 || utxos[0].Amount.Int64() != 5000 ||
+		utxos[1].TxIndex != 1 || utxos[1].Amount.Int64() != 17891 || utxos[1].BlockHeight != 2866858 ||
+		utxos[2].TxIndex != 2 || utxos[3].Amount.Int64() != 7000 {
+		for _, utxo := range utxos {
+			t.Logf("%+v", utxo)
+		}
+		t.Errorf("unexpected %d utxos", len(utxos))
+	}
+	if _, err := backend.ListUTXOs(ctx, "mkHS9ne12qx9pS9VojpwU5xtRd4T7X7ZUt"); err == nil {
+		t.Errorf("paging that can not advance should fail")
+	}
+
+	tests := []struct {
+		targetBlocks int
+		feeRate      float64
+	}{
+		{1, 30}, {6, 12.5}, {12, 4},
+	}
+	for _, test := range tests {
+		feeRate, err := backend.EstimateFee(ctx, test.targetBlocks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if feeRate != test.feeRate {
+			t.Errorf("target %d: got fee rate %v, expected %v", test.targetBlocks, feeRate, test.feeRate)
+		}
+	}
+
+	height, err := backend.TipHeight(ctx)
+	if err != nil || height != 2866877 {
+		t.Errorf("unexpected tip height %d %v", height, err)
+	}
+
+	if _, err := backend.Broadcast(ctx, "00"); err == nil {
+		t.Errorf("broadcast should fail on http status 400")
+	}
+}
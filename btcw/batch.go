@@ -0,0 +1,111 @@
+package btcw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ErrDuplicateRecipient is returned when a batch pays the same address twice.
+var ErrDuplicateRecipient = errors.New("duplicate recipient")
+
+// Payment is an output of a batch transaction.
+type Payment struct {
+	Address string
+	// Amount is in satoshis.
+	Amount int64
+}
+
+// BatchTransaction is a signed transaction paying many recipients.
+type BatchTransaction struct {
+	Tx *wire.MsgTx
+	// Hex is the serialized transaction to broadcast.
+	Hex string
+	// OutputIndex maps the address of each payment to its output.
+	OutputIndex map[string]uint32
+	// ChangeIndex is the index of the change output, -1 if there is none.
+	ChangeIndex int
+	Fee         int64
+}
+
+// Txid returns the id of the transaction.
+func (b *BatchTransaction) Txid() string {
+	return b.Tx.TxHash().String()
+}
+
+// CreateBatchTransaction returns a signed transaction that makes payments
+// from fromAddress, with the change going back to fromAddress. Every address
+// must be for the network of the client and be paid once, and no amount may
+// be dust. ErrTxTooLarge is returned if the payments and the UTXOs paying
+// them do not fit in a standard transaction.
+func (c *Client) CreateBatchTransaction(ctx context.Context, fromAddress string, payments []Payment, privKey []byte, opts TransferOptions) (*BatchTransaction, error) {
+	if len(payments) == 0 {
+		return nil, errors.New("batch has no payments")
+	}
+	changeScript, err := payToAddressScript(fromAddress, c.Net)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([]*wire.TxOut, len(payments))
+	outputIndex := make(map[string]uint32, len(payments))
+	recipients := make(map[string]string, len(payments))
+	for i, payment := range payments {
+		pkScript, err := payToAddressScript(payment.Address, c.Net)
+		if err != nil {
+			return nil, fmt.Errorf("payment %d: %w", i, err)
+		}
+		// 대소문자만 다른 bech32 주소도 같은 주소다.
+		decoded, _ := btcutil.DecodeAddress(payment.Address, c.Net)
+		if other, ok := recipients[decoded.EncodeAddress()]; ok {
+			return nil, fmt.Errorf("payment %d: %w %s, also paid as %s", i, ErrDuplicateRecipient, payment.Address, other)
+		}
+		recipients[decoded.EncodeAddress()] = payment.Address
+
+		outputs[i] = wire.NewTxOut(payment.Amount, pkScript)
+		if threshold := dustThreshold(outputs[i]); payment.Amount < threshold {
+			return nil, fmt.Errorf("payment %d to %s: %w", i, payment.Address, &DustError{Amount: payment.Amount, Threshold: threshold})
+		}
+		outputIndex[payment.Address] = uint32(i)
+	}
+
+	tx, selection, err := c.fundTransaction(ctx, fromAddress, outputs, wire.NewTxOut(0, changeScript), privKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	signedHex, err := encodeTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &BatchTransaction{
+		Tx:          tx,
+		Hex:         signedHex,
+		OutputIndex: outputIndex,
+		ChangeIndex: -1,
+		Fee:         selection.Fee,
+	}
+	if selection.Change > 0 {
+		batch.ChangeIndex = len(outputs)
+	}
+	return batch, nil
+}
+
+// SendBatch creates a batch transaction and broadcasts it.
+func (c *Client) SendBatch(ctx context.Context, fromAddress string, payments []Payment, privKey []byte, opts TransferOptions) (*BatchTransaction, error) {
+	batch, err := c.CreateBatchTransaction(ctx, fromAddress, payments, privKey, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+	if _, err := c.SendRawTransaction(ctx, batch.Hex); err != nil {
+		c.releaseReservation(batch.Hex)
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
+	}
+	c.markSpent(batch.Hex)
+	log.Printf("%s: %d payments, txHash: %s", fromAddress, len(payments), batch.Txid())
+	return batch, nil
+}
@@ -0,0 +1,126 @@
+package btcw
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestClientCreateBatchTransaction(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	fromAddress := wallet.GetSegwitAddress(net)
+
+	backend := newFakeBackend()
+	backend.feeRate = 3
+	backend.utxos[fromAddress] = newTestUTXOs(30000, 40000, 50000)
+	client := NewClient(backend, net)
+
+	payments := []Payment{
+		{Address: "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q", Amount: 20000},
+		{Address: "mkgMpQSbsJMBffCbVMUk6KryCuFGAtnFo9", Amount: 30000},
+		{Address: wallet.GetTaprootAddress(net), Amount: 15000},
+	}
+	batch, err := client.SendBatch(ctx, fromAddress, payments, wallet.PrivateKeyToBytes(), TransferOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.broadcast) != 1 || backend.broadcast[0] != batch.Hex {
+		t.Errorf("batch should be broadcast")
+	}
+
+	tx := batch.Tx
+	for _, payment := range payments {
+		index, ok := batch.OutputIndex[payment.Address]
+		if !ok || tx.TxOut[index].Value != payment.Amount {
+			t.Errorf("unexpected output for %s", payment.Address)
+		}
+		if script := addressScript(t, payment.Address, net); !bytes.Equal(tx.TxOut[index].PkScript, script) {
+			t.Errorf("output %d does not pay %s", index, payment.Address)
+		}
+	}
+	if batch.ChangeIndex != 3 || len(tx.TxOut) != 4 {
+		t.Fatalf("expected a change output, got %d outputs", len(tx.TxOut))
+	}
+
+	var spent []*UTXO
+	var inputTotal, outputTotal int64
+	for _, in := range tx.TxIn {
+		utxo := backend.utxos[fromAddress][in.PreviousOutPoint.Index]
+		spent = append(spent, utxo)
+		inputTotal += utxo.Amount.Int64()
+	}
+	for _, out := range tx.TxOut {
+		outputTotal += out.Value
+	}
+	if batch.Fee != inputTotal-outputTotal {
+		t.Errorf("fee %d, expected %d", batch.Fee, inputTotal-outputTotal)
+	}
+	// input, output 마다 올림하므로 조금 더 낼 수 있다.
+	if vsize := (txWeight(tx) + 3) / 4; batch.Fee < 3*vsize || batch.Fee > 3*(vsize+int64(len(tx.TxIn))+2) {
+		t.Errorf("fee %d for %d vB", batch.Fee, vsize)
+	}
+	verifyTransaction(t, tx, spent)
+}
+
+func TestClientCreateBatchTransactionErrors(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	fromAddress := wallet.GetSegwitAddress(net)
+
+	backend := newFakeBackend()
+	backend.utxos[fromAddress] = newTestUTXOs(30000)
+	client := NewClient(backend, net)
+
+	tests := []struct {
+		name     string
+		payments []Payment
+		err      error
+	}{
+		{"empty", nil, nil},
+		{"duplicate", []Payment{
+			{Address: "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q", Amount: 1000},
+			{Address: "TB1QF9K7GAHVKCNGAZW3HWACLH6DQMC0G38KE3295Q", Amount: 2000},
+		}, ErrDuplicateRecipient},
+		{"dust", []Payment{
+			{Address: "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q", Amount: 1000},
+			{Address: "mkgMpQSbsJMBffCbVMUk6KryCuFGAtnFo9", Amount: 545},
+		}, ErrDust},
+		{"mainnet address", []Payment{
+			{Address: "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", Amount: 1000},
+		}, ErrInvalidAddress},
+		{"insufficient funds", []Payment{
+			{Address: "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q", Amount: 20000},
+			{Address: "mkgMpQSbsJMBffCbVMUk6KryCuFGAtnFo9", Amount: 10000},
+		}, ErrInsufficientFunds},
+	}
+	for _, test := range tests {
+		_, err := client.CreateBatchTransaction(ctx, fromAddress, test.payments, wallet.PrivateKeyToBytes(), TransferOptions{})
+		if err == nil || (test.err != nil && !errors.Is(err, test.err)) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+
+	// P2WPKH output 3300 개는 표준 weight 400000 을 넘는다.
+	backend.utxos[fromAddress] = newTestUTXOs(5000000)
+	payments := make([]Payment, 3300)
+	for i := range payments {
+		var hash [20]byte
+		binary.BigEndian.PutUint32(hash[:], uint32(i))
+		address, _ := btcutil.NewAddressWitnessPubKeyHash(hash[:], net)
+		payments[i] = Payment{Address: address.EncodeAddress(), Amount: 1000}
+	}
+	if _, err := client.CreateBatchTransaction(ctx, fromAddress, payments, wallet.PrivateKeyToBytes(), TransferOptions{}); !errors.Is(err, ErrTxTooLarge) {
+		t.Errorf("expected too large, got %v", err)
+	}
+	if _, err := client.CreateBatchTransaction(ctx, fromAddress, payments[:3000], wallet.PrivateKeyToBytes(), TransferOptions{}); err != nil {
+		t.Error(err)
+	}
+}
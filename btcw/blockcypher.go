@@ -0,0 +1,351 @@
+package btcw
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+type AddressEndpoint struct {
+	Address            string  `json:"address"`
+	TotalReceived      int     `json:"total_received"`
+	TotalSent          int     `json:"total_sent"`
+	Balance            int     `json:"balance"`
+	UnconfirmedBalance int     `json:"unconfirmed_balance"`
+	FinalBalance       int     `json:"final_balance"`
+	NTx                int     `json:"n_tx"`
+	UnconfirmedNTx     int     `json:"unconfirmed_n_tx"`
+	FinalNTx           int     `json:"final_n_tx"`
+	TxRefs             []TxRef `json:"txrefs"`
+	// UnconfirmedTxRefs are the refs of mempool transactions. They are all in
+	// the first page.
+	UnconfirmedTxRefs []TxRef `json:"unconfirmed_txrefs"`
+	// HasMore is true if there are older txrefs than the page.
+	HasMore bool `json:"hasMore"`
+}
+
+type TxRef struct {
+	TxHash        string `json:"tx_hash"`
+	BlockHeight   int    `json:"block_height"`
+	TxInputN      int    `json:"tx_input_n"`
+	TxOutputN     int    `json:"tx_output_n"`
+	Value         int    `json:"value"`
+	RefBalance    int    `json:"ref_balance"`
+	Spent         bool   `json:"spent"`
+	Confirmations int    `json:"confirmations"`
+	Confirmed     string `json:"confirmed"`
+	DoubleSpend   bool   `json:"double_spend"`
+}
+
+// BlockCypherChain is the response of the chain endpoint.
+// https://www.blockcypher.com/dev/bitcoin/#chain-endpoint
+type BlockCypherChain struct {
+	Name               string `json:"name"`
+	Height             int64  `json:"height"`
+	Hash               string `json:"hash"`
+	HighFeePerKb       int64  `json:"high_fee_per_kb"`
+	MediumFeePerKb     int64  `json:"medium_fee_per_kb"`
+	LowFeePerKb        int64  `json:"low_fee_per_kb"`
+	UnconfirmedTxCount int    `json:"unconfirmed_count"`
+}
+
+// BlockCypherBackend is a ChainBackend for the BlockCypher REST API.
+type BlockCypherBackend struct {
+	// BaseURL is the chain URL, e.g. https://api.blockcypher.com/v1/btc/test3
+	BaseURL string
+	// Token is an optional API token, sent as the token query parameter.
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewBlockCypherBackend returns a backend for mainnet or testnet3.
+func NewBlockCypherBackend(net *chaincfg.Params) (*BlockCypherBackend, error) {
+	baseURL := "https://api.blockcypher.com/v1/btc/"
+	switch net.Net {
+	case wire.MainNet:
+		baseURL += "main"
+	case wire.TestNet3:
+		baseURL += "test3"
+	default:
+		return nil, fmt.Errorf("blockcypher does not support network %s", net.Name)
+	}
+	return &BlockCypherBackend{BaseURL: baseURL, HTTPClient: http.DefaultClient}, nil
+}
+
+// GetAddressEndpoint gets address from BlockCypher: the backend of
+// DefaultClient if it is BlockCypher, else BlockCypher for its network.
+func GetAddressEndpoint(ctx context.Context, address string) (*AddressEndpoint, error) {
+	client, err := defaultClient()
+	if err != nil {
+		return nil, err
+	}
+	backend, ok := client.Backend.(*BlockCypherBackend)
+	if !ok {
+		if backend, err = NewBlockCypherBackend(client.Net); err != nil {
+			return nil, err
+		}
+	}
+	result, err := backend.GetAddressEndpoint(ctx, address)
+	return result, wrapBackendError(err)
+}
+
+func (b *BlockCypherBackend) GetAddressEndpoint(ctx context.Context, address string) (*AddressEndpoint, error) {
+	/*
+		response example
+		{
+			"address": "miruDdUTqQv9eXMPPwXL73b9iy4gv8KeuH",
+			"total_received": 33115,
+			"total_sent": 0,
+			"balance": 33115,
+			"unconfirmed_balance": 0,
+			"final_balance": 33115,
+			"n_tx": 2,
+			"unconfirmed_n_tx": 0,
+			"final_n_tx": 2,
+			"txrefs": [
+				{
+					"tx_hash": "bc26416ce0facd6733b26f5322b21f834ec9206eea9525ffd44e6c1102810fad",
+					"block_height": 2866858,
+					"tx_input_n": -1,
+					"tx_output_n": 1,
+					"value": 17891,
+					"ref_balance": 33115,
+					"spent": false,
+					"confirmations": 19,
+					"confirmed": "2024-07-08T05:25:30Z",
+					"double_spend": false
+				},
+				{
+					"tx_hash": "ae2db75b9d7bfb9bc3c39e238e1479df3021b206039f67528024ab123990feb4",
+					"block_height": 2822254,
+					"tx_input_n": -1,
+					"tx_output_n": 0,
+					"value": 15224,
+					"ref_balance": 15224,
+					"spent": false,
+					"confirmations": 44623,
+					"confirmed": "2024-06-24T09:03:35Z",
+					"double_spend": false
+				}
+			],
+			"tx_url": "https://api.blockcypher.com/v1/btc/test3/txs/"
+		}
+	*/
+	return b.getAddress(ctx, address, url.Values{"unspentOnly": {"true"}})
+}
+
+func (b *BlockCypherBackend) getAddress(ctx context.Context, address string, query url.Values) (*AddressEndpoint, error) {
+	var addressEndpoint AddressEndpoint
+	if err := b.do(ctx, http.MethodGet, "/addrs/"+url.PathEscape(address), query, nil, &addressEndpoint); err != nil {
+		return nil, fmt.Errorf("failed to get UTXOs of %s: %w", address, err)
+	}
+	return &addressEndpoint, nil
+}
+
+// blockCypherPageLimit is the number of txrefs requested per page, the
+// maximum of the API.
+const blockCypherPageLimit = 2000
+
+// ListUTXOs returns the confirmed and the unconfirmed unspent outputs of
+// address, reading every page of txrefs.
+func (b *BlockCypherBackend) ListUTXOs(ctx context.Context, address string) ([]*UTXO, error) {
+	type outpoint struct {
+		hash  string
+		index int
+	}
+	seen := make(map[outpoint]bool)
+	var utxos []*UTXO
+	add := func(txRefs []TxRef) int {
+		added := 0
+		for _, txRef := range txRefs {
+			// tx_output_n 이 -1 이면 input 이다.
+			key := outpoint{txRef.TxHash, txRef.TxOutputN}
+			if txRef.Spent || txRef.TxOutputN < 0 || seen[key] {
+				continue
+			}
+			seen[key] = true
+			utxo := &UTXO{
+				Hash:      txRef.TxHash,
+				TxIndex:   txRef.TxOutputN,
+				Amount:    big.NewInt(int64(txRef.Value)),
+				Spendable: true,
+				PKScript:  nil,
+			}
+			// block_height is -1 for unconfirmed transactions
+			if txRef.BlockHeight > 0 {
+				utxo.BlockHeight = int64(txRef.BlockHeight)
+			}
+			utxos = append(utxos, utxo)
+			added++
+		}
+		return added
+	}
+
+	query := url.Values{"unspentOnly": {"true"}, "limit": {fmt.Sprint(blockCypherPageLimit)}}
+	for page := 0; ; page++ {
+		addressEndpoint, err := b.getAddress(ctx, address, query)
+		if err != nil {
+			return nil, err
+		}
+		if page == 0 {
+			add(addressEndpoint.UnconfirmedTxRefs)
+		}
+		added := add(addressEndpoint.TxRefs)
+		if !addressEndpoint.HasMore || len(addressEndpoint.TxRefs) == 0 {
+			return utxos, nil
+		}
+
+		// before 는 그 높이 미만의 txref 를 준다. 마지막 높이의 txref 가 다음 page 로
+		// 넘어갈 수 있으므로 그 높이부터 다시 읽고 중복은 뺀다.
+		lowest := addressEndpoint.TxRefs[0].BlockHeight
+		for _, txRef := range addressEndpoint.TxRefs {
+			if txRef.BlockHeight < lowest {
+				lowest = txRef.BlockHeight
+			}
+		}
+		if added == 0 {
+			// 한 높이의 txref 가 page 보다 많으면 더 진행할 수 없다. 건너뛰면 UTXO 가 빠진다.
+			return nil, fmt.Errorf("blockcypher: can not page txrefs of %s below height %d", address, lowest+1)
+		}
+		query.Set("before", fmt.Sprint(lowest+1))
+	}
+}
+
+func (b *BlockCypherBackend) GetBalance(ctx context.Context, address string) (*GetBalanceResponse, error) {
+	// https://www.blockcypher.com/dev/?go#address-balance-endpoint
+	var result GetBalanceResponse
+	if err := b.do(ctx, http.MethodGet, "/addrs/"+url.PathEscape(address)+"/balance", nil, nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to get balance of %s: %w", address, err)
+	}
+	return &result, nil
+}
+
+func (b *BlockCypherBackend) GetChain(ctx context.Context) (*BlockCypherChain, error) {
+	var chain BlockCypherChain
+	if err := b.do(ctx, http.MethodGet, "", nil, nil, &chain); err != nil {
+		return nil, fmt.Errorf("failed to get chain: %w", err)
+	}
+	return &chain, nil
+}
+
+// EstimateFee maps targetBlocks to BlockCypher's high (1-2 blocks), medium
+// (3-6 blocks) or low fee and returns it in sat/vB.
+func (b *BlockCypherBackend) EstimateFee(ctx context.Context, targetBlocks int) (float64, error) {
+	chain, err := b.GetChain(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	feePerKb := chain.LowFeePerKb
+	if targetBlocks <= 2 {
+		feePerKb = chain.HighFeePerKb
+	} else if targetBlocks <= 6 {
+		feePerKb = chain.MediumFeePerKb
+	}
+	return float64(feePerKb) / 1000, nil
+}
+
+func (b *BlockCypherBackend) TipHeight(ctx context.Context) (int64, error) {
+	chain, err := b.GetChain(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return chain.Height, nil
+}
+
+func (b *BlockCypherBackend) Broadcast(ctx context.Context, rawTxHex string) (string, error) {
+	// https://www.blockcypher.com/dev/bitcoin/#push-raw-transaction-endpoint
+	request := struct {
+		Tx string `json:"tx"`
+	}{rawTxHex}
+	var response struct {
+		Tx struct {
+			Hash string `json:"hash"`
+		} `json:"tx"`
+	}
+	if err := b.do(ctx, http.MethodPost, "/txs/push", nil, request, &response); err != nil {
+		return "", fmt.Errorf("failed to send raw transaction: %w", err)
+	}
+	return response.Tx.Hash, nil
+}
+
+func (b *BlockCypherBackend) GetTransaction(ctx context.Context, txid string) (*wire.MsgTx, error) {
+	var response struct {
+		Hex string `json:"hex"`
+	}
+	query := url.Values{"includeHex": {"true"}}
+	if err := b.do(ctx, http.MethodGet, "/txs/"+url.PathEscape(txid), query, nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get transaction %s: %w", txid, err)
+	}
+	return decodeTxHex(response.Hex)
+}
+
+func (b *BlockCypherBackend) do(ctx context.Context, method string, path string, query url.Values, request interface{}, result interface{}) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	if b.Token != "" {
+		query.Set("token", b.Token)
+	}
+	requestURL := b.BaseURL + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+	if err != nil {
+		return err
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := b.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	return json.Unmarshal(respBody, result)
+}
+
+func decodeTxHex(txHex string) (*wire.MsgTx, error) {
+	raw, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
@@ -1,21 +1,20 @@
 package btcw
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
-	"io"
-	"log"
 	"math/big"
-	"net/http"
 
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/base58"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
 	"golang.org/x/crypto/ripemd160"
 )
 
@@ -23,62 +22,154 @@ const BITCOIN_MAINNET_VERSION = 0x00
 const BITCOIN_TESTNET_VERSION = 0x6f
 
 type Wallet struct {
-	PrivateKey ecdsa.PrivateKey
+	PrivateKey *btcec.PrivateKey
 	PublicKey  []byte
+	// Compressed 가 false 이면 PublicKey 는 65 bytes 비압축 공개키이고
+	// legacy 주소도 비압축 공개키로 만든다. (uncompressed WIF 로 가져온 키)
+	Compressed bool
+	// Net 은 WIF 로 가져온 경우 WIF 의 network 이다. 그 외에는 nil.
+	Net *chaincfg.Params
 }
 
-type Wallets struct {
-	Wallets map[string]*Wallet
+func CreateNewWallet() (*Wallet, error) {
+	// 개인키와 공개키를 생성한다.
+	// 비트코인은 P-256 이 아닌 secp256k1 곡선을 사용한다.
+	privateKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return GetWalletFromPrivateKey(privateKey), nil
 }
 
-func CreateNewWallet() *Wallet {
-	// 개인키와 공개키를 생성한다.
-	// https://pkg.go.dev/crypto/ecdsa
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+func GetWalletFromPrivateKey(privateKey *btcec.PrivateKey) *Wallet {
+	// 공개키는 압축(33 bytes) 형식으로 저장한다.
+	return &Wallet{
+		PrivateKey: privateKey,
+		PublicKey:  privateKey.PubKey().SerializeCompressed(),
+		Compressed: true,
+	}
+}
+
+// WalletFromWIF imports a WIF private key, keeping its network and compression flag.
+func WalletFromWIF(wifStr string) (*Wallet, error) {
+	wif, err := btcutil.DecodeWIF(wifStr)
 	if err != nil {
-		log.Panic(err) // 현재 함수를 즉시 멈춘다. 상위 함수로 전파되어 프로그램이 종료된다.
+		return nil, err
 	}
 
-	return GetWalletFromPrivateKey(privateKey)
+	net, err := wifNet(wif)
+	if err != nil {
+		return nil, err
+	}
 
-	//publicKey := append(privateKey.PublicKey.X.Bytes(), privateKey.PublicKey.Y.Bytes()...)
+	publicKey := wif.SerializePubKey()
+	return &Wallet{
+		PrivateKey: wif.PrivKey,
+		PublicKey:  publicKey,
+		Compressed: wif.CompressPubKey,
+		Net:        net,
+	}, nil
+}
 
-	//return &Wallet{*privateKey, publicKey}
+func wifNet(wif *btcutil.WIF) (*chaincfg.Params, error) {
+	// testnet3, regtest, signet 은 WIF prefix 가 같으므로 testnet3 으로 본다.
+	for _, net := range []*chaincfg.Params{&chaincfg.MainNetParams, &chaincfg.TestNet3Params} {
+		if wif.IsForNet(net) {
+			return net, nil
+		}
+	}
+	return nil, errors.New("unknown WIF network")
 }
 
-func GetWalletFromPrivateKey(privateKey *ecdsa.PrivateKey) *Wallet {
-	publicKey := append(privateKey.PublicKey.X.Bytes(), privateKey.PublicKey.Y.Bytes()...)
-	return &Wallet{*privateKey, publicKey}
+// ToWIF exports the private key as WIF for net.
+func (w Wallet) ToWIF(net *chaincfg.Params, compressed bool) (string, error) {
+	wif, err := btcutil.NewWIF(w.PrivateKey, net, compressed)
+	if err != nil {
+		return "", err
+	}
+	return wif.String(), nil
 }
 
-func GetECDSAPrivateKeyFromPrivateKeyString(privateKey string) ecdsa.PrivateKey {
-	var e ecdsa.PrivateKey
-	e.D, _ = new(big.Int).SetString(privateKey, 16)
-	e.PublicKey.Curve = elliptic.P256()
-	e.PublicKey.X, e.PublicKey.Y = e.PublicKey.Curve.ScalarBaseMult(e.D.Bytes())
-	return e
+// GetPrivateKeyFromPrivateKeyString parses a hex encoded secp256k1 private key.
+// Keys shorter than 32 bytes (e.g. printed with %x from a big.Int) are left padded.
+func GetPrivateKeyFromPrivateKeyString(privateKeyStr string) (*btcec.PrivateKey, error) {
+	if len(privateKeyStr) > 64 {
+		return nil, errors.New("private key is longer than 32 bytes")
+	}
+	if len(privateKeyStr)%2 == 1 {
+		privateKeyStr = "0" + privateKeyStr
+	}
+	privateKeyBytes, err := hex.DecodeString(privateKeyStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// btcec.PrivKeyFromBytes 는 N 이상의 값을 조용히 mod N 하므로 범위를 먼저 확인한다.
+	var scalar btcec.ModNScalar
+	padded := make([]byte, 32)
+	copy(padded[32-len(privateKeyBytes):], privateKeyBytes)
+	if overflow := scalar.SetByteSlice(padded); overflow || scalar.IsZero() {
+		return nil, errors.New("private key is out of range for secp256k1")
+	}
+
+	return btcec.PrivKeyFromScalar(&scalar), nil
+}
+
+func GetECDSAPrivateKeyFromPrivateKeyString(privateKey string) (*ecdsa.PrivateKey, error) {
+	privKey, err := GetPrivateKeyFromPrivateKeyString(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return privKey.ToECDSA(), nil
 }
 
-func GetWalletFromPrivateKeyString(privateKeyStr string) *Wallet {
-	privateKey := GetECDSAPrivateKeyFromPrivateKeyString(privateKeyStr)
-	return GetWalletFromPrivateKey(&privateKey)
+func GetWalletFromPrivateKeyString(privateKeyStr string) (*Wallet, error) {
+	privateKey, err := GetPrivateKeyFromPrivateKeyString(privateKeyStr)
+	if err != nil {
+		return nil, err
+	}
+	return GetWalletFromPrivateKey(privateKey), nil
+}
+
+// GetP256LegacyAddressFromPrivateKeyString returns the P2PKH address that older
+// versions of this package derived for privateKeyStr on the P-256 curve.
+// Those addresses have no secp256k1 key behind them, so this is only useful to
+// locate funds that were sent to them before migrating the key.
+func GetP256LegacyAddressFromPrivateKeyString(privateKeyStr string, bitcoinVersion byte) (string, error) {
+	d, ok := new(big.Int).SetString(privateKeyStr, 16)
+	if !ok {
+		return "", errors.New("invalid hex private key")
+	}
+	curve := elliptic.P256()
+	x, y := curve.ScalarBaseMult(d.Bytes())
+	publicKey := append(x.Bytes(), y.Bytes()...)
+	return base58.CheckEncode(hashPublicKey(publicKey), bitcoinVersion), nil
 }
 
 func hashPublicKey(publicKey []byte) []byte {
 	// Public key를 먼저 SHA-256으로 해싱하고, 이 결과를 RIPEMD-160으로 또다시 해싱
 	publicSHA256 := sha256.Sum256(publicKey)
 
+	// hash.Hash 의 Write 는 error 를 반환하지 않는다.
 	RIPEMD160Hasher := ripemd160.New()
-	_, err := RIPEMD160Hasher.Write(publicSHA256[:])
-	if err != nil {
-		log.Panic(err)
-	}
+	RIPEMD160Hasher.Write(publicSHA256[:])
 
 	publicRIPEMD160 := RIPEMD160Hasher.Sum(nil)
 	return publicRIPEMD160
 
 }
 
+// SerializePubKeyCompressed returns the 33 byte compressed public key.
+func (w Wallet) SerializePubKeyCompressed() []byte {
+	return w.PrivateKey.PubKey().SerializeCompressed()
+}
+
+// SerializePubKeyUncompressed returns the 65 byte uncompressed public key.
+func (w Wallet) SerializePubKeyUncompressed() []byte {
+	return w.PrivateKey.PubKey().SerializeUncompressed()
+}
+
 func (w Wallet) GetLegacyAddress(bitcoinVersion byte) string {
 	publicKeyHash := hashPublicKey(w.PublicKey)
 	return base58.CheckEncode(publicKeyHash, bitcoinVersion)
@@ -91,54 +182,101 @@ func (w Wallet) GetLegacyAddress2(chaincfgParams *chaincfg.Params) string {
 }
 
 func (w Wallet) GetSegwitAddress(chaincfgParams *chaincfg.Params) string {
-	publicKeyHash := hashPublicKey(w.PublicKey)
+	// segwit 은 압축 공개키만 허용한다.
+	publicKeyHash := hashPublicKey(w.SerializePubKeyCompressed())
 	rtn, _ := btcutil.NewAddressWitnessPubKeyHash(publicKeyHash, chaincfgParams)
 	return rtn.EncodeAddress()
 }
 
+// GetNestedSegwitAddress returns the P2SH-P2WPKH ("3..." on mainnet) address of the wallet.
+func (w Wallet) GetNestedSegwitAddress(chaincfgParams *chaincfg.Params) string {
+	// 개인키로부터 만든 공개키이므로 실패하지 않는다.
+	address, _ := GetNestedSegwitAddressFromPubKeyBytes(w.SerializePubKeyCompressed(), chaincfgParams)
+	return address
+}
+
+// GetTaprootAddress returns the BIP86 key-path only P2TR address of the wallet.
+func (w Wallet) GetTaprootAddress(chaincfgParams *chaincfg.Params) string {
+	address, _ := GetTaprootAddressFromPubKeyBytes(w.SerializePubKeyCompressed(), chaincfgParams)
+	return address
+}
+
 func (w Wallet) PrivateKeyToBytes() []byte {
-	return w.PrivateKey.D.Bytes()
+	return w.PrivateKey.Serialize()
 }
 
-func GetLegacyAddressFromPubKeyString(serializedPubKey string, net *chaincfg.Params) string {
+func GetLegacyAddressFromPubKeyString(serializedPubKey string, net *chaincfg.Params) (string, error) {
 	// P2PKH 주소를 public key 로부터 생성
 	serializedPubKeyBytes, err := hex.DecodeString(serializedPubKey)
 	if err != nil {
-		log.Panic(err)
+		return "", err
 	}
 	return GetLegacyAddressFromPubKeyBytes(serializedPubKeyBytes, net)
 }
 
-func GetLegacyAddressFromPubKeyBytes(serializedPubKey []byte, net *chaincfg.Params) string {
+func GetLegacyAddressFromPubKeyBytes(serializedPubKey []byte, net *chaincfg.Params) (string, error) {
 	// P2PKH 주소를 public key 로부터 생성
 	addressPubKey, err := btcutil.NewAddressPubKey(serializedPubKey, net)
 	if err != nil {
-		log.Panic(err)
+		return "", err
 	}
-	return addressPubKey.EncodeAddress()
+	return addressPubKey.EncodeAddress(), nil
 }
 
-func GetSegwitAddressFromPubKeyBytes(serializedPubKey []byte, net *chaincfg.Params) string {
+func GetSegwitAddressFromPubKeyBytes(serializedPubKey []byte, net *chaincfg.Params) (string, error) {
 	// 네이티브 세그윗 주소(Bech32 주소)
 	// segwit mainnet p2wpkh address 를 pubkey 로부터 생성
 	// bc1 으로 시작하고 소문자로만 구성됨
 	pkHash := hashPublicKey(serializedPubKey)
 	addressPubKey, err := btcutil.NewAddressWitnessPubKeyHash(pkHash, net)
 	if err != nil {
-		log.Panic(err)
+		return "", err
 	}
-	return addressPubKey.EncodeAddress()
+	return addressPubKey.EncodeAddress(), nil
 }
 
-func GetSegwitAddressFromPubKeyString(serializedPubKey string, net *chaincfg.Params) string {
+func GetSegwitAddressFromPubKeyString(serializedPubKey string, net *chaincfg.Params) (string, error) {
 	// 네이티브 세그윗 주소(Bech32 주소)
 	serializedPubKeyBytes, err := hex.DecodeString(serializedPubKey)
 	if err != nil {
-		log.Panic(err)
+		return "", err
 	}
 	return GetSegwitAddressFromPubKeyBytes(serializedPubKeyBytes, net)
 }
 
+func GetNestedSegwitAddressFromPubKeyBytes(serializedPubKey []byte, net *chaincfg.Params) (string, error) {
+	// 세그윗 주소(P2SH-P2WPKH), mainnet 에서 3 으로 시작함
+	// P2WPKH script 를 redeem script 로 하는 P2SH 주소
+	redeemScript, err := payToWitnessPubKeyHashScript(serializedPubKey)
+	if err != nil {
+		return "", err
+	}
+	addressScriptHash, err := btcutil.NewAddressScriptHash(redeemScript, net)
+	if err != nil {
+		return "", err
+	}
+	return addressScriptHash.EncodeAddress(), nil
+}
+
+func GetTaprootAddressFromPubKeyBytes(serializedPubKey []byte, net *chaincfg.Params) (string, error) {
+	// 탭루트 주소(P2TR, Bech32m 주소), mainnet 에서 bc1p 로 시작함
+	// BIP86: script path 없이 internal key 를 tweak 한 output key 를 사용한다.
+	internalKey, err := btcec.ParsePubKey(serializedPubKey)
+	if err != nil {
+		return "", err
+	}
+	outputKey := txscript.ComputeTaprootKeyNoScript(internalKey)
+	addressTaproot, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), net)
+	if err != nil {
+		return "", err
+	}
+	return addressTaproot.EncodeAddress(), nil
+}
+
+func payToWitnessPubKeyHashScript(serializedPubKey []byte) ([]byte, error) {
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(hashPublicKey(serializedPubKey)).Script()
+}
+
 type GetBalanceResponse struct {
 	Address            string `json:"address"`
 	TotalReceived      int    `json:"total_received"`
@@ -151,7 +289,8 @@ type GetBalanceResponse struct {
 	FinalNTx           int    `json:"final_n_tx"`
 }
 
-func GetBalance(network int, address string) (*GetBalanceResponse, error) {
+// GetBalance gets the balance of address from the DefaultBackend of net.
+func GetBalance(ctx context.Context, net *chaincfg.Params, address string) (*GetBalanceResponse, error) {
 	/*
 	   GetBalanceResponse example
 	   {
@@ -166,35 +305,9 @@ func GetBalance(network int, address string) (*GetBalanceResponse, error) {
 	   "final_n_tx": 7
 	   }
 	*/
-	// https://www.blockcypher.com/dev/?go#address-balance-endpoint
-	baseUrl := "https://api.blockcypher.com/v1/btc/"
-	if network == BITCOIN_MAINNET_VERSION {
-		baseUrl += "main/"
-	} else if network == BITCOIN_TESTNET_VERSION {
-		baseUrl += "test3/"
-	}
-	baseUrl += "addrs/" + address + "/balance"
-
-	res, err := http.Get(baseUrl)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	if res.StatusCode != 200 {
-		return nil, errors.New("failed to get balance")
-	}
-	defer res.Body.Close()
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	var result GetBalanceResponse
-	err = json.Unmarshal(body, &result)
+	backend, err := DefaultBackend(net)
 	if err != nil {
-		log.Panic(err)
+		return nil, err
 	}
-
-	return &result, nil
-
+	return NewClient(backend, net).GetBalance(ctx, address)
 }
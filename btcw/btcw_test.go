@@ -1,6 +1,9 @@
 package btcw
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
 	"fmt"
 	"testing"
 
@@ -11,8 +14,8 @@ import (
 
 func TestCreateNewWallet(t *testing.T) {
 	// 새로운 지갑을 생성합니다. private key 와 public key 가 생성됐는지 확인합니다.
-	wallet := CreateNewWallet()
-	if wallet.PrivateKey.D == nil || wallet.PublicKey == nil {
+	wallet, _ := CreateNewWallet()
+	if wallet.PrivateKey == nil || wallet.PublicKey == nil {
 		t.Errorf("createNewWallet failed to generate a wallet with non-nil keys")
 	}
 
@@ -31,7 +34,7 @@ func TestGetPrivateKeyFromString(t *testing.T) {
 	// private key string 으로부터 private key 객체를 생성합니다.
 	// 생성된 private key 객체의 private key string 값과 비교합니다.
 	privKeyStr := "18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725"
-	privKey := GetECDSAPrivateKeyFromPrivateKeyString(privKeyStr)
+	privKey, _ := GetECDSAPrivateKeyFromPrivateKeyString(privKeyStr)
 	privKey2 := fmt.Sprintf("%x", privKey.D)
 	if privKey2 != privKeyStr {
 		t.Errorf("GetECDSAPrivateKeyFromPrivateKeyString failed to generate a private key from a string")
@@ -40,10 +43,10 @@ func TestGetPrivateKeyFromString(t *testing.T) {
 
 func TestCreateWalletGetWalletFromPrivStr(t *testing.T) {
 	// 지갑을 생성한 다음 private key 같은 지갑 객체를 다시 생성 같은지 확인
-	wallet := CreateNewWallet()
+	wallet, _ := CreateNewWallet()
 	address1 := wallet.GetLegacyAddress(BITCOIN_MAINNET_VERSION)
-	privKey := fmt.Sprintf("%x", wallet.PrivateKey.D)
-	wallet2 := GetWalletFromPrivateKeyString(privKey)
+	privKey := hex.EncodeToString(wallet.PrivateKeyToBytes())
+	wallet2, _ := GetWalletFromPrivateKeyString(privKey)
 	address2 := wallet2.GetLegacyAddress(BITCOIN_MAINNET_VERSION)
 
 	if address1 != address2 {
@@ -54,9 +57,9 @@ func TestCreateWalletGetWalletFromPrivStr(t *testing.T) {
 func TestCreateWalletFromPrivKeyStr(t *testing.T) {
 	// private key string 으로부터 지갑을 생성한 다음 mainnet, testnet 주소가 같은지 확인합니다.
 	privKeyStr := "d010d7a9b9a57f30e38a700fb5e2e367531f950e7a548939d4cfc8d5efc867b8"
-	mainnetAddressExpected := "1DBsZm5jEX4V6brneoD1C4riDKQqPpnGmN"
-	testnetAddressExpected := "mshprpAi3YVjsiLQNNBP1z535K1YK6NLfa"
-	wallet := GetWalletFromPrivateKeyString(privKeyStr)
+	mainnetAddressExpected := "1KXWke7oddgrXtEvyLk3ANLKTd1wCkkozr"
+	testnetAddressExpected := "mz3U3hCnSf87JziYguiQzHYeKcce9CUXEB"
+	wallet, _ := GetWalletFromPrivateKeyString(privKeyStr)
 	mainnetAddress := wallet.GetLegacyAddress(BITCOIN_MAINNET_VERSION)
 	testnetAddress := wallet.GetLegacyAddress(BITCOIN_TESTNET_VERSION)
 
@@ -69,6 +72,75 @@ func TestCreateWalletFromPrivKeyStr(t *testing.T) {
 
 }
 
+func TestWalletPubKeySerialization(t *testing.T) {
+	// 압축 공개키는 33 bytes(02/03 prefix), 비압축 공개키는 65 bytes(04 prefix) 이다.
+	wallet, _ := GetWalletFromPrivateKeyString("18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725")
+	compressed := wallet.SerializePubKeyCompressed()
+	uncompressed := wallet.SerializePubKeyUncompressed()
+
+	if hex.EncodeToString(compressed) != "0250863ad64a87ae8a2fe83c1af1a8403cb53f53e486d8511dad8a04887e5b2352" {
+		t.Errorf("unexpected compressed public key %x", compressed)
+	}
+	if len(uncompressed) != 65 || uncompressed[0] != 0x04 {
+		t.Errorf("unexpected uncompressed public key %x", uncompressed)
+	}
+	if !bytes.Equal(wallet.PublicKey, compressed) {
+		t.Errorf("wallet public key should be the compressed serialization")
+	}
+
+	// bitcoin wiki 의 예제 키. 비압축 공개키로 만든 주소와 같아야 한다.
+	address, _ := GetLegacyAddressFromPubKeyBytes(uncompressed, &chaincfg.MainNetParams)
+	if address != "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM" {
+		t.Errorf("unexpected uncompressed address %s", address)
+	}
+}
+
+func TestGetPrivateKeyFromShortString(t *testing.T) {
+	// %x 로 출력된 big.Int 는 앞의 0 이 빠질 수 있다. 32 bytes 로 채워서 읽어야 한다.
+	privKey, err := GetPrivateKeyFromPrivateKeyString("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(privKey.Serialize()) != "0000000000000000000000000000000000000000000000000000000000000001" {
+		t.Errorf("short private key should be left padded")
+	}
+
+	invalidKeys := []string{
+		"",
+		"0",
+		"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", // N
+		"18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a20632172500",
+		"zz",
+	}
+	for _, key := range invalidKeys {
+		if _, err := GetPrivateKeyFromPrivateKeyString(key); err == nil {
+			t.Errorf("expected error for private key %q", key)
+		}
+	}
+}
+
+func TestGetP256LegacyAddress(t *testing.T) {
+	// 이전 버전(P-256)에서 만들어진 주소를 찾을 수 있어야 한다.
+	privKeyStr := "d010d7a9b9a57f30e38a700fb5e2e367531f950e7a548939d4cfc8d5efc867b8"
+	mainnetAddress, err := GetP256LegacyAddressFromPrivateKeyString(privKeyStr, BITCOIN_MAINNET_VERSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testnetAddress, _ := GetP256LegacyAddressFromPrivateKeyString(privKeyStr, BITCOIN_TESTNET_VERSION)
+
+	if mainnetAddress != "1DBsZm5jEX4V6brneoD1C4riDKQqPpnGmN" {
+		t.Errorf("unexpected P-256 mainnet address %s", mainnetAddress)
+	}
+	if testnetAddress != "mshprpAi3YVjsiLQNNBP1z535K1YK6NLfa" {
+		t.Errorf("unexpected P-256 testnet address %s", testnetAddress)
+	}
+
+	wallet, _ := GetWalletFromPrivateKeyString(privKeyStr)
+	if wallet.GetLegacyAddress(BITCOIN_MAINNET_VERSION) == mainnetAddress {
+		t.Errorf("secp256k1 address should differ from the P-256 address")
+	}
+}
+
 func TestCreateNewAddress(t *testing.T) {
 	newPrivKey, err := btcec.NewPrivateKey()
 	if err != nil {
@@ -95,7 +167,7 @@ func TestCreateNewAddress(t *testing.T) {
 func TestGetBalance(t *testing.T) {
 	// 지갑 주소로부터 잔고를 조회합니다.
 	address := "miruDdUTqQv9eXMPPwXL73b9iy4gv8KeuH"
-	balance, err := GetBalance(BITCOIN_TESTNET_VERSION, address)
+	balance, err := GetBalance(context.Background(), &chaincfg.TestNet3Params, address)
 	if err != nil {
 		t.Errorf("GetBalance failed to get the balance")
 	}
@@ -131,6 +203,62 @@ func TestAddressFromWif(t *testing.T) {
 	}
 }
 
+func TestWalletFromWIF(t *testing.T) {
+	// 같은 개인키라도 WIF 의 압축 여부에 따라 legacy 주소가 달라진다.
+	tests := []struct {
+		wif           string
+		net           *chaincfg.Params
+		compressed    bool
+		legacyAddress string
+	}{
+		{"5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ", &chaincfg.MainNetParams, false, "1GAehh7TsJAHuUAeKZcXf5CnwuGuGgyX2S"},
+		{"KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617", &chaincfg.MainNetParams, true, "1LoVGDgRs9hTfTNJNuXKSpywcbdvwRXpmK"},
+		{"cUsfNynj7UsBvjLPeb6TjnxA4SFThiZwXr7Az5TxJGDWUFGQbbZv", &chaincfg.TestNet3Params, true, "myQCR5hm5R6NWoKn4o5MSLGiLTrKdk2AbD"},
+	}
+
+	for _, test := range tests {
+		wallet, err := WalletFromWIF(test.wif)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wallet.Net != test.net || wallet.Compressed != test.compressed {
+			t.Errorf("%s: unexpected network %v or compressed %v", test.wif, wallet.Net.Name, wallet.Compressed)
+		}
+		if address := wallet.GetLegacyAddress(test.net.PubKeyHashAddrID); address != test.legacyAddress {
+			t.Errorf("%s: got legacy address %s, expected %s", test.wif, address, test.legacyAddress)
+		}
+
+		wif, err := wallet.ToWIF(wallet.Net, wallet.Compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wif != test.wif {
+			t.Errorf("%s: WIF round trip returned %s", test.wif, wif)
+		}
+	}
+
+	if _, err := WalletFromWIF("5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTK"); err == nil {
+		t.Errorf("WIF with a bad checksum should be rejected")
+	}
+}
+
+func TestWalletToWIF(t *testing.T) {
+	wallet, _ := GetWalletFromPrivateKeyString("18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725")
+
+	uncompressedWif, _ := wallet.ToWIF(&chaincfg.MainNetParams, false)
+	imported, err := WalletFromWIF(uncompressedWif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.GetLegacyAddress(BITCOIN_MAINNET_VERSION) != "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM" {
+		t.Errorf("uncompressed WIF should give the uncompressed P2PKH address")
+	}
+	// segwit 주소는 항상 압축 공개키로 만든다.
+	if imported.GetSegwitAddress(&chaincfg.MainNetParams) != wallet.GetSegwitAddress(&chaincfg.MainNetParams) {
+		t.Errorf("segwit address should not depend on the WIF compression flag")
+	}
+}
+
 func TestWifStringFromLegacyAddressMainnet(t *testing.T) {
 	net := &chaincfg.MainNetParams
 	wif1 := "L1KJbwoL9R7oj1yjDmEnT3tWURcdXd3eEgSdJ8PWfrJb8YMWwwD4"
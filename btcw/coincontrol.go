@@ -0,0 +1,203 @@
+package btcw
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ErrCoinControl is matched by a CoinControlError.
+var ErrCoinControl = errors.New("coin control constraint can not be met")
+
+// CoinControlError is returned when a required UTXO can not be spent.
+type CoinControlError struct {
+	Outpoint wire.OutPoint
+	Reason   string
+}
+
+func (e *CoinControlError) Error() string {
+	return fmt.Sprintf("%v: utxo %s %s", ErrCoinControl, e.Outpoint, e.Reason)
+}
+
+func (e *CoinControlError) Is(target error) bool {
+	return target == ErrCoinControl
+}
+
+// TransferOptions restrict the UTXOs a transfer spends.
+type TransferOptions struct {
+	// Include are spent in any case. Coin selection only adds other UTXOs if
+	// they are not enough.
+	Include []wire.OutPoint
+	// Exclude are never spent.
+	Exclude []wire.OutPoint
+	// Frozen are never spent either. Unlike Exclude the set is kept across
+	// transfers, see OpenFrozenSet.
+	Frozen *FrozenSet
+}
+
+// outpoint returns the outpoint of utxo.
+func (utxo *UTXO) outpoint() (wire.OutPoint, error) {
+	hash, err := chainhash.NewHashFromStr(utxo.Hash)
+	if err != nil {
+		return wire.OutPoint{}, fmt.Errorf("invalid utxo hash %s: %w", utxo.Hash, err)
+	}
+	return *wire.NewOutPoint(hash, uint32(utxo.TxIndex)), nil
+}
+
+// apply splits utxos into the required ones and the ones coin selection may
+// pick from. excluded is the value of the UTXOs left out by the options or
+// reserved.
+func (opts *TransferOptions) apply(utxos []*UTXO, reserved map[wire.OutPoint]bool) (required, candidates []*UTXO, excluded int64, err error) {
+	byOutpoint := make(map[wire.OutPoint]*UTXO, len(utxos))
+	for _, utxo := range utxos {
+		outpoint, err := utxo.outpoint()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		byOutpoint[outpoint] = utxo
+	}
+
+	skip := make(map[wire.OutPoint]string)
+	for _, outpoint := range opts.Exclude {
+		skip[outpoint] = "is excluded"
+	}
+	if opts.Frozen != nil {
+		for _, outpoint := range opts.Frozen.Outpoints() {
+			skip[outpoint] = "is frozen"
+		}
+	}
+	for outpoint := range reserved {
+		skip[outpoint] = "is reserved"
+	}
+
+	included := make(map[wire.OutPoint]bool, len(opts.Include))
+	for _, outpoint := range opts.Include {
+		if reason, ok := skip[outpoint]; ok {
+			return nil, nil, 0, &CoinControlError{Outpoint: outpoint, Reason: reason}
+		}
+		utxo, ok := byOutpoint[outpoint]
+		if !ok {
+			return nil, nil, 0, &CoinControlError{Outpoint: outpoint, Reason: "is not unspent"}
+		}
+		if included[outpoint] {
+			continue
+		}
+		included[outpoint] = true
+		required = append(required, utxo)
+	}
+
+	for _, utxo := range utxos {
+		outpoint, _ := utxo.outpoint()
+		switch _, skipped := skip[outpoint]; {
+		case included[outpoint]:
+		case skipped:
+			excluded += utxo.Amount.Int64()
+		default:
+			candidates = append(candidates, utxo)
+		}
+	}
+	return required, candidates, excluded, nil
+}
+
+// FrozenSet is a set of outpoints that are never spent, e.g. tainted UTXOs.
+// It is saved to a file on every change.
+type FrozenSet struct {
+	path      string
+	mu        sync.Mutex
+	outpoints map[wire.OutPoint]bool
+}
+
+type frozenSetFile struct {
+	Outpoints []string `json:"outpoints"`
+}
+
+// NewFrozenSet returns an empty frozen set that is not saved.
+func NewFrozenSet() *FrozenSet {
+	return &FrozenSet{outpoints: make(map[wire.OutPoint]bool)}
+}
+
+// OpenFrozenSet reads the frozen set saved at path. The file is created on
+// the first change if it does not exist.
+func OpenFrozenSet(path string) (*FrozenSet, error) {
+	fs := NewFrozenSet()
+	fs.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file frozenSetFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid frozen set %s: %w", path, err)
+	}
+	for _, s := range file.Outpoints {
+		outpoint, err := wire.NewOutPointFromString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid frozen set %s: %w", path, err)
+		}
+		fs.outpoints[*outpoint] = true
+	}
+	return fs, nil
+}
+
+// Freeze adds outpoints to the set.
+func (fs *FrozenSet) Freeze(outpoints ...wire.OutPoint) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, outpoint := range outpoints {
+		fs.outpoints[outpoint] = true
+	}
+	return fs.save()
+}
+
+// Unfreeze removes outpoints from the set.
+func (fs *FrozenSet) Unfreeze(outpoints ...wire.OutPoint) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, outpoint := range outpoints {
+		delete(fs.outpoints, outpoint)
+	}
+	return fs.save()
+}
+
+func (fs *FrozenSet) IsFrozen(outpoint wire.OutPoint) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.outpoints[outpoint]
+}
+
+// Outpoints returns the frozen outpoints.
+func (fs *FrozenSet) Outpoints() []wire.OutPoint {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	outpoints := make([]wire.OutPoint, 0, len(fs.outpoints))
+	for outpoint := range fs.outpoints {
+		outpoints = append(outpoints, outpoint)
+	}
+	return outpoints
+}
+
+func (fs *FrozenSet) save() error {
+	if fs.path == "" {
+		return nil
+	}
+	var file frozenSetFile
+	for outpoint := range fs.outpoints {
+		file.Outpoints = append(file.Outpoints, outpoint.String())
+	}
+	sort.Strings(file.Outpoints)
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fs.path, data, 0600)
+}
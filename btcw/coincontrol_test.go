@@ -0,0 +1,149 @@
+package btcw
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func testOutpoint(t *testing.T, utxo *UTXO) wire.OutPoint {
+	t.Helper()
+	outpoint, err := utxo.outpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outpoint
+}
+
+func TestFrozenSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "frozen.json")
+	utxos := newTestUTXOs(1000, 2000)
+
+	fs, err := OpenFrozenSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Freeze(testOutpoint(t, utxos[0]), testOutpoint(t, utxos[1])); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Unfreeze(testOutpoint(t, utxos[1])); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err = OpenFrozenSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fs.IsFrozen(testOutpoint(t, utxos[0])) || fs.IsFrozen(testOutpoint(t, utxos[1])) || len(fs.Outpoints()) != 1 {
+		t.Errorf("unexpected frozen outpoints %v", fs.Outpoints())
+	}
+}
+
+func TestClientCoinControl(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	fromAddress := wallet.GetSegwitAddress(net)
+	toAddress := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
+
+	backend := newFakeBackend()
+	utxos := newTestUTXOs(5000, 50000, 20000)
+	backend.utxos[fromAddress] = utxos
+	client := NewClient(backend, net)
+	client.Rand = rand.New(rand.NewSource(1))
+
+	spends := func(signedHex string) map[uint32]bool {
+		tx, err := decodeTxHex(signedHex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spent := make(map[uint32]bool)
+		for _, in := range tx.TxIn {
+			spent[in.PreviousOutPoint.Index] = true
+		}
+		return spent
+	}
+
+	// 작은 UTXO 도 지정하면 사용한다.
+	signedHex, err := client.CreateTransferTransactionWithOptions(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 30000,
+		TransferOptions{Include: []wire.OutPoint{testOutpoint(t, utxos[0])}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent := spends(signedHex); !spent[0] || len(spent) < 2 {
+		t.Errorf("expected the included utxo and more, got %v", spent)
+	}
+
+	frozen := NewFrozenSet()
+	if err := frozen.Freeze(testOutpoint(t, utxos[2])); err != nil {
+		t.Fatal(err)
+	}
+	opts := TransferOptions{Exclude: []wire.OutPoint{testOutpoint(t, utxos[0])}, Frozen: frozen}
+	signedHex, err = client.CreateTransferTransactionWithOptions(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 10000, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent := spends(signedHex); !spent[1] || len(spent) != 1 {
+		t.Errorf("expected only the 50000 sat utxo, got %v", spent)
+	}
+
+	_, err = client.CreateTransferTransactionWithOptions(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 50000, opts)
+	if !errors.Is(err, ErrInsufficientFunds) || !strings.Contains(err.Error(), "25000 satoshis are excluded") {
+		t.Errorf("expected insufficient funds, got %v", err)
+	}
+
+	missing := testOutpoint(t, newTestUTXO(9, 1000, nil))
+	tests := []struct {
+		name    string
+		include wire.OutPoint
+		reason  string
+	}{
+		{"frozen", testOutpoint(t, utxos[2]), "is frozen"},
+		{"excluded", testOutpoint(t, utxos[0]), "is excluded"},
+		{"missing", missing, "is not unspent"},
+	}
+	for _, test := range tests {
+		include := opts
+		include.Include = []wire.OutPoint{test.include}
+		_, err := client.CreateTransferTransactionWithOptions(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 1000, include)
+		var coinControlErr *CoinControlError
+		if !errors.Is(err, ErrCoinControl) || !errors.As(err, &coinControlErr) ||
+			coinControlErr.Outpoint != test.include || coinControlErr.Reason != test.reason {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func TestSelectCoinsRequired(t *testing.T) {
+	// 수수료보다 작은 UTXO 도 required 이면 사용한다.
+	required := newTestUTXO(9, 1500, nil)
+	utxos := newTestUTXOs(12000, 3000, 7000, 4000)
+	params := newTestCoinSelectionParams(2800, 20, 1)
+	params.Required = []*UTXO{required}
+
+	selection, err := SelectCoins(utxos, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCoinSelection(t, selection, params)
+	if selection.UTXOs[0] != required || len(selection.UTXOs) < 2 {
+		t.Errorf("expected the required utxo first, got %+v", selection)
+	}
+
+	// required 만으로 충분하면 다른 UTXO 는 쓰지 않는다.
+	params.Required = []*UTXO{utxos[0]}
+	selection, err = SelectCoins(utxos[1:], params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCoinSelection(t, selection, params)
+	if selection.Algorithm != AlgorithmManual || len(selection.UTXOs) != 1 || selection.Change == 0 {
+		t.Errorf("expected the required utxo with change, got %+v", selection)
+	}
+}
@@ -0,0 +1,387 @@
+package btcw
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// DefaultLongTermFeeRate is the fee rate in sat/vB UTXOs are expected to be
+// spent at later, as bitcoind's -consolidatefeerate. Below it the waste
+// metric prefers spending more inputs now.
+const DefaultLongTermFeeRate = 10
+
+// bnbMaxTries is the number of branches BnB visits, as in Bitcoin Core.
+const bnbMaxTries = 100000
+
+// knapsackIterations is the number of random passes of the knapsack solver.
+const knapsackIterations = 1000
+
+// Coin selection algorithms.
+const (
+	AlgorithmBnB      = "bnb"
+	AlgorithmKnapsack = "knapsack"
+	AlgorithmSRD      = "srd"
+	// AlgorithmManual is used when the required UTXOs pay the target alone.
+	AlgorithmManual = "manual"
+)
+
+// CoinSelectionParams are the amounts and sizes coin selection needs. Sizes
+// are in vbytes and fee rates in sat/vB.
+type CoinSelectionParams struct {
+	// Target is the sum of the payment outputs.
+	Target  int64
+	FeeRate float64
+	// LongTermFeeRate is used by the waste metric, DefaultLongTermFeeRate if 0.
+	LongTermFeeRate float64
+	// BaseVSize is the size of the transaction without inputs and change,
+	// i.e. the header and the payment outputs.
+	BaseVSize int64
+	// InputVSize returns the size of the input spending utxo.
+	InputVSize func(utxo *UTXO) int64
+	// ChangeOutputVSize is the size of the change output and ChangeSpendVSize
+	// the size of the input that spends it later.
+	ChangeOutputVSize int64
+	ChangeSpendVSize  int64
+	// MinChange is the smallest change worth creating, e.g. the dust
+	// threshold of the change output. A smaller change is left to the fee.
+	MinChange int64
+	// Required are spent in any case, even if their value is below the fee
+	// to spend them. The other UTXOs are only selected if they are not enough.
+	Required []*UTXO
+	// Rand is used by the knapsack and SRD algorithms. A new source seeded
+	// with the current time is used if it is nil.
+	Rand *rand.Rand
+}
+
+// CoinSelection is the result of SelectCoins.
+type CoinSelection struct {
+	UTXOs []*UTXO
+	// Total is the sum of the selected UTXOs.
+	Total int64
+	// Fee is the fee of the transaction including the change output if there
+	// is one, plus a change that was too small to create.
+	Fee    int64
+	Change int64
+	// Waste is the waste metric of Bitcoin Core. Lower is better.
+	Waste     int64
+	Algorithm string
+}
+
+// coinCandidate is a UTXO with its fees at the current and long term fee
+// rates.
+type coinCandidate struct {
+	utxo           *UTXO
+	value          int64
+	fee            int64
+	longTermFee    int64
+	effectiveValue int64
+}
+
+func feeForVSize(feeRate float64, vsize int64) int64 {
+	return int64(math.Ceil(feeRate * float64(vsize)))
+}
+
+// SelectCoins picks the UTXOs to pay params.Target like Bitcoin Core does.
+// Branch and bound searches for a selection that needs no change. Knapsack and
+// single random draw find selections with change. The selection with the
+// lowest waste is returned.
+func SelectCoins(utxos []*UTXO, params CoinSelectionParams) (*CoinSelection, error) {
+	if params.Target <= 0 {
+		return nil, errors.New("coin selection target must be positive")
+	}
+	if params.InputVSize == nil {
+		return nil, errors.New("coin selection needs InputVSize")
+	}
+	longTermFeeRate := params.LongTermFeeRate
+	if longTermFeeRate <= 0 {
+		longTermFeeRate = DefaultLongTermFeeRate
+	}
+	rng := params.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	newCandidate := func(utxo *UTXO) coinCandidate {
+		vsize := params.InputVSize(utxo)
+		candidate := coinCandidate{
+			utxo:        utxo,
+			value:       utxo.Amount.Int64(),
+			fee:         feeForVSize(params.FeeRate, vsize),
+			longTermFee: feeForVSize(longTermFeeRate, vsize),
+		}
+		candidate.effectiveValue = candidate.value - candidate.fee
+		return candidate
+	}
+
+	var required, candidates []coinCandidate
+	var available, requiredValue int64
+	for _, utxo := range params.Required {
+		candidate := newCandidate(utxo)
+		required = append(required, candidate)
+		available += candidate.value
+		requiredValue += candidate.effectiveValue
+	}
+	for _, utxo := range utxos {
+		candidate := newCandidate(utxo)
+		available += candidate.value
+		// 수수료보다 작은 UTXO 는 사용할수록 손해다.
+		if candidate.effectiveValue > 0 {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	baseFee := feeForVSize(params.FeeRate, params.BaseVSize)
+	target := params.Target + baseFee
+	changeFee := feeForVSize(params.FeeRate, params.ChangeOutputVSize)
+	costOfChange := changeFee + feeForVSize(longTermFeeRate, params.ChangeSpendVSize)
+
+	effectiveTotal := requiredValue
+	for _, candidate := range candidates {
+		effectiveTotal += candidate.effectiveValue
+	}
+	if effectiveTotal < target {
+		return nil, &InsufficientFundsError{Available: available, Required: target}
+	}
+
+	newSelection := func(selected []coinCandidate, algorithm string) *CoinSelection {
+		selection := &CoinSelection{Algorithm: algorithm}
+		var effectiveValue int64
+		for _, candidate := range append(append([]coinCandidate(nil), required...), selected...) {
+			selection.UTXOs = append(selection.UTXOs, candidate.utxo)
+			selection.Total += candidate.value
+			selection.Fee += candidate.fee
+			selection.Waste += candidate.fee - candidate.longTermFee
+			effectiveValue += candidate.effectiveValue
+		}
+		selection.Fee += baseFee
+
+		excess := effectiveValue - target
+		if change := excess - changeFee; algorithm != AlgorithmBnB && change >= params.MinChange && change > 0 {
+			selection.Change = change
+			selection.Fee += changeFee
+			selection.Waste += costOfChange
+		} else {
+			// 잔돈을 만들지 않으면 남는 금액은 수수료가 된다.
+			selection.Fee += excess
+			selection.Waste += excess
+		}
+		return selection
+	}
+
+	if requiredValue >= target {
+		return newSelection(nil, AlgorithmManual), nil
+	}
+
+	// required UTXO 가 내는 만큼을 뺀 나머지를 고른다.
+	var results []*CoinSelection
+	selectionTarget := target - requiredValue
+	if selected := selectBnB(candidates, selectionTarget, costOfChange, params.FeeRate > longTermFeeRate); selected != nil {
+		results = append(results, newSelection(selected, AlgorithmBnB))
+	}
+	changeTarget := selectionTarget + changeFee
+	if selected := selectKnapsack(candidates, changeTarget, params.MinChange, rng); selected != nil {
+		results = append(results, newSelection(selected, AlgorithmKnapsack))
+	}
+	if selected := selectSRD(candidates, changeTarget+params.MinChange, rng); selected != nil {
+		results = append(results, newSelection(selected, AlgorithmSRD))
+	}
+	if len(results) == 0 {
+		return nil, &InsufficientFundsError{Available: available, Required: target}
+	}
+
+	best := results[0]
+	for _, result := range results[1:] {
+		// waste 가 같으면 input 이 많은 쪽을 고른다. (Bitcoin Core 와 같다)
+		if result.Waste < best.Waste || (result.Waste == best.Waste && len(result.UTXOs) > len(best.UTXOs)) {
+			best = result
+		}
+	}
+	return best, nil
+}
+
+// selectBnB is the branch and bound search of Bitcoin Core. It searches for
+// the selection with the lowest waste whose effective value is between target
+// and target+costOfChange, so that no change is needed.
+func selectBnB(candidates []coinCandidate, target, costOfChange int64, feeRateHigh bool) []coinCandidate {
+	pool := append([]coinCandidate(nil), candidates...)
+	sort.SliceStable(pool, func(i, j int) bool {
+		return pool[i].effectiveValue > pool[j].effectiveValue
+	})
+
+	var currAvailable int64
+	for _, candidate := range pool {
+		currAvailable += candidate.effectiveValue
+	}
+	if currAvailable < target {
+		return nil
+	}
+
+	var currValue, currWaste int64
+	var currSelection, bestSelection []int
+	bestWaste := int64(math.MaxInt64)
+
+	index := 0
+	for tries := 0; tries < bnbMaxTries; tries, index = tries+1, index+1 {
+		backtrack := false
+		if currValue+currAvailable < target || currValue > target+costOfChange ||
+			(currWaste > bestWaste && feeRateHigh) {
+			// 더 가도 해가 없거나, 수수료가 높을 때 waste 가 이미 최선보다 크다.
+			backtrack = true
+		} else if currValue >= target {
+			currWaste += currValue - target
+			if currWaste <= bestWaste {
+				bestSelection = append(bestSelection[:0], currSelection...)
+				bestWaste = currWaste
+			}
+			currWaste -= currValue - target
+			backtrack = true
+		}
+
+		if backtrack {
+			if len(currSelection) == 0 {
+				break
+			}
+			last := currSelection[len(currSelection)-1]
+			// 마지막으로 넣은 UTXO 뒤의 UTXO 들을 다시 남은 금액에 더한다.
+			for index--; index > last; index-- {
+				currAvailable += pool[index].effectiveValue
+			}
+			// 마지막으로 넣은 UTXO 를 빼는 branch 로 간다.
+			currValue -= pool[index].effectiveValue
+			currWaste -= pool[index].fee - pool[index].longTermFee
+			currSelection = currSelection[:len(currSelection)-1]
+		} else {
+			candidate := pool[index]
+			currAvailable -= candidate.effectiveValue
+			// 바로 앞의 같은 UTXO 를 뺀 branch 에서 이 UTXO 를 넣는 것은 이미 본 경우와 같다.
+			if len(currSelection) == 0 || index-1 == currSelection[len(currSelection)-1] ||
+				candidate.effectiveValue != pool[index-1].effectiveValue || candidate.fee != pool[index-1].fee {
+				currSelection = append(currSelection, index)
+				currValue += candidate.effectiveValue
+				currWaste += candidate.fee - candidate.longTermFee
+			}
+		}
+	}
+
+	if len(bestSelection) == 0 {
+		return nil
+	}
+	selected := make([]coinCandidate, len(bestSelection))
+	for i, index := range bestSelection {
+		selected[i] = pool[index]
+	}
+	return selected
+}
+
+// selectKnapsack is the knapsack solver of Bitcoin Core. It looks for a
+// subset that hits target exactly or leaves at least minChange, and falls
+// back to the smallest UTXO that covers target on its own.
+func selectKnapsack(candidates []coinCandidate, target, minChange int64, rng *rand.Rand) []coinCandidate {
+	pool := append([]coinCandidate(nil), candidates...)
+	rng.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+
+	var applicable []coinCandidate
+	var lowestLarger *coinCandidate
+	var totalLower int64
+	for i := range pool {
+		candidate := pool[i]
+		switch {
+		case candidate.effectiveValue == target:
+			return []coinCandidate{candidate}
+		case candidate.effectiveValue < target+minChange:
+			applicable = append(applicable, candidate)
+			totalLower += candidate.effectiveValue
+		case lowestLarger == nil || candidate.effectiveValue < lowestLarger.effectiveValue:
+			lowestLarger = &pool[i]
+		}
+	}
+
+	if totalLower == target {
+		return applicable
+	}
+	if totalLower < target {
+		if lowestLarger == nil {
+			return nil
+		}
+		return []coinCandidate{*lowestLarger}
+	}
+
+	sort.SliceStable(applicable, func(i, j int) bool {
+		return applicable[i].effectiveValue > applicable[j].effectiveValue
+	})
+	best, bestValue := approximateBestSubset(applicable, totalLower, target, rng)
+	if bestValue != target && totalLower >= target+minChange {
+		best, bestValue = approximateBestSubset(applicable, totalLower, target+minChange, rng)
+	}
+
+	// 잔돈이 너무 작게 남는 조합보다 큰 UTXO 하나가 나으면 그것을 쓴다.
+	if lowestLarger != nil &&
+		((bestValue != target && bestValue < target+minChange) || lowestLarger.effectiveValue <= bestValue) {
+		return []coinCandidate{*lowestLarger}
+	}
+
+	var selected []coinCandidate
+	for i, included := range best {
+		if included {
+			selected = append(selected, applicable[i])
+		}
+	}
+	return selected
+}
+
+// approximateBestSubset randomly searches for the subset of candidates with
+// the smallest sum not below target.
+func approximateBestSubset(candidates []coinCandidate, totalLower, target int64, rng *rand.Rand) ([]bool, int64) {
+	best := make([]bool, len(candidates))
+	for i := range best {
+		best[i] = true
+	}
+	bestValue := totalLower
+
+	included := make([]bool, len(candidates))
+	for rep := 0; rep < knapsackIterations && bestValue != target; rep++ {
+		for i := range included {
+			included[i] = false
+		}
+		var total int64
+		reachedTarget := false
+		for pass := 0; pass < 2 && !reachedTarget; pass++ {
+			for i, candidate := range candidates {
+				// 첫 pass 는 무작위로, 두 번째 pass 는 남은 것을 모두 넣어 본다.
+				if (pass == 0 && rng.Intn(2) == 1) || (pass == 1 && !included[i]) {
+					total += candidate.effectiveValue
+					included[i] = true
+					if total >= target {
+						reachedTarget = true
+						if total < bestValue {
+							bestValue = total
+							copy(best, included)
+						}
+						total -= candidate.effectiveValue
+						included[i] = false
+					}
+				}
+			}
+		}
+	}
+	return best, bestValue
+}
+
+// selectSRD is single random draw: UTXOs are added in random order until
+// target is reached.
+func selectSRD(candidates []coinCandidate, target int64, rng *rand.Rand) []coinCandidate {
+	order := rng.Perm(len(candidates))
+	var selected []coinCandidate
+	var value int64
+	for _, i := range order {
+		selected = append(selected, candidates[i])
+		value += candidates[i].effectiveValue
+		if value >= target {
+			return selected
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package btcw
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func newTestCoinSelectionParams(target int64, feeRate float64, seed int64) CoinSelectionParams {
+	return CoinSelectionParams{
+		Target:            target,
+		FeeRate:           feeRate,
+		BaseVSize:         10,
+		InputVSize:        func(*UTXO) int64 { return 100 },
+		ChangeOutputVSize: 30,
+		ChangeSpendVSize:  70,
+		MinChange:         300,
+		Rand:              rand.New(rand.NewSource(seed)),
+	}
+}
+
+func newTestUTXOs(amounts ...int64) []*UTXO {
+	utxos := make([]*UTXO, len(amounts))
+	for i, amount := range amounts {
+		utxos[i] = newTestUTXO(i, amount, nil)
+	}
+	return utxos
+}
+
+func checkCoinSelection(t *testing.T, selection *CoinSelection, params CoinSelectionParams) {
+	t.Helper()
+	var total int64
+	for _, utxo := range selection.UTXOs {
+		total += utxo.Amount.Int64()
+	}
+	if total != selection.Total || total != params.Target+selection.Fee+selection.Change {
+		t.Errorf("unbalanced selection %+v", selection)
+	}
+	if selection.Change != 0 && selection.Change < params.MinChange {
+		t.Errorf("change %d is below %d", selection.Change, params.MinChange)
+	}
+}
+
+func TestSelectCoinsBnB(t *testing.T) {
+	// 20 sat/vB 에서 input 수수료는 2000, effective value 는 1000, 2000, 5000, 10000
+	utxos := newTestUTXOs(12000, 3000, 7000, 4000)
+	params := newTestCoinSelectionParams(2800, 20, 1)
+
+	selection, err := SelectCoins(utxos, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCoinSelection(t, selection, params)
+	if selection.Algorithm != AlgorithmBnB || selection.Change != 0 || selection.Total != 7000 {
+		t.Fatalf("expected a changeless bnb selection of 3000+4000, got %+v", selection)
+	}
+	// input 2 개의 (2000-1000) 와 잔돈이 없으므로 excess 0
+	if selection.Fee != 2*2000+200 || selection.Waste != 2000 {
+		t.Errorf("unexpected fee %d waste %d", selection.Fee, selection.Waste)
+	}
+}
+
+func TestSelectCoinsWithChange(t *testing.T) {
+	// effective value 8500 ~ 9800 을 만드는 조합이 없어 bnb 는 실패한다.
+	utxos := newTestUTXOs(12000, 3000, 7000, 4000)
+	params := newTestCoinSelectionParams(8300, 20, 1)
+
+	selection, err := SelectCoins(utxos, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCoinSelection(t, selection, params)
+	if selection.Algorithm == AlgorithmBnB || selection.Change == 0 {
+		t.Errorf("expected a selection with change, got %+v", selection)
+	}
+	if len(selection.UTXOs) != 1 || selection.Total != 12000 {
+		t.Errorf("expected the 12000 sat utxo, got %+v", selection)
+	}
+}
+
+func TestSelectCoinsWaste(t *testing.T) {
+	amounts := []int64{200000}
+	for i := 0; i < 10; i++ {
+		amounts = append(amounts, 10000)
+	}
+	utxos := newTestUTXOs(amounts...)
+
+	// long term fee rate 보다 비싸면 input 을 적게 쓴다.
+	params := newTestCoinSelectionParams(50000, 50, 1)
+	selection, err := SelectCoins(utxos, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCoinSelection(t, selection, params)
+	if len(selection.UTXOs) != 1 {
+		t.Errorf("expected 1 input at a high fee rate, got %d", len(selection.UTXOs))
+	}
+
+	// 싸면 지금 작은 UTXO 들을 모은다.
+	params = newTestCoinSelectionParams(50000, 1, 1)
+	selection, err = SelectCoins(utxos, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCoinSelection(t, selection, params)
+	if len(selection.UTXOs) < 2 {
+		t.Errorf("expected more inputs at a low fee rate, got %d", len(selection.UTXOs))
+	}
+}
+
+func TestSelectCoinsDeterministic(t *testing.T) {
+	var amounts []int64
+	for i := int64(1); i <= 40; i++ {
+		amounts = append(amounts, i*1237)
+	}
+	utxos := newTestUTXOs(amounts...)
+
+	first, err := SelectCoins(utxos, newTestCoinSelectionParams(100000, 3, 42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		selection, err := SelectCoins(utxos, newTestCoinSelectionParams(100000, 3, 42))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if selection.Algorithm != first.Algorithm || len(selection.UTXOs) != len(first.UTXOs) {
+			t.Fatalf("selection changed with the same seed: %+v %+v", first, selection)
+		}
+		for j := range selection.UTXOs {
+			if selection.UTXOs[j] != first.UTXOs[j] {
+				t.Fatalf("selection changed with the same seed at input %d", j)
+			}
+		}
+	}
+}
+
+func TestSelectCoinsInsufficientFunds(t *testing.T) {
+	// 20 sat/vB 에서 1500 sat UTXO 는 수수료 2000 보다 작아 쓰지 않는다.
+	utxos := newTestUTXOs(1500, 1500, 1500, 12000)
+	params := newTestCoinSelectionParams(9000, 20, 1)
+	selection, err := SelectCoins(utxos, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCoinSelection(t, selection, params)
+	if len(selection.UTXOs) != 1 || selection.Total != 12000 {
+		t.Errorf("uneconomical utxos should not be selected, got %+v", selection)
+	}
+
+	_, err = SelectCoins(utxos, newTestCoinSelectionParams(10000, 20, 1))
+	var insufficient *InsufficientFundsError
+	if !errors.Is(err, ErrInsufficientFunds) || !errors.As(err, &insufficient) || insufficient.Available != 16500 {
+		t.Errorf("expected insufficient funds, got %v", err)
+	}
+}
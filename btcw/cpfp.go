@@ -0,0 +1,247 @@
+package btcw
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// CPFPTransaction is a signed child transaction that pays the fee of its
+// unconfirmed parent.
+type CPFPTransaction struct {
+	Tx *wire.MsgTx
+	// Hex is the serialized transaction to broadcast.
+	Hex string
+	// Parent is the txid of the parent transaction.
+	Parent    string
+	ParentFee int64
+	Fee       int64
+	// PackageFeeRate is the fee rate of the parent and the child together in
+	// sat/vB.
+	PackageFeeRate float64
+}
+
+// Txid returns the id of the transaction.
+func (c *CPFPTransaction) Txid() string {
+	return c.Tx.TxHash().String()
+}
+
+// CreateCPFPTransaction returns a signed transaction spending the outputs of
+// the unconfirmed transaction parentTxid to address back to address. The fee
+// of the child is chosen so that the parent and the child pay feeRate sat/vB
+// together. Unconfirmed ancestors of the parent are not counted.
+func (c *Client) CreateCPFPTransaction(ctx context.Context, address string, parentTxid string, feeRate float64, privKey []byte) (*CPFPTransaction, error) {
+	parent, err := c.GetTransaction(ctx, parentTxid)
+	if err != nil {
+		return nil, err
+	}
+	parentFee, parentVSize, err := c.mempoolFee(ctx, parentTxid, parent)
+	if err != nil {
+		return nil, err
+	}
+	if parentFeeRate := float64(parentFee) / float64(parentVSize); parentFeeRate >= feeRate {
+		return nil, fmt.Errorf("parent %s already pays %.3f sat/vB", parentTxid, parentFeeRate)
+	}
+
+	// 부모의 output 중 아직 쓰이지 않은 우리 output 을 쓴다.
+	parentUTXOs, err := c.parentUTXOs(ctx, address, parentTxid, parent)
+	if err != nil {
+		return nil, err
+	}
+	reserved, err := c.reservedOutpoints()
+	if err != nil {
+		return nil, err
+	}
+	var utxos []*UTXO
+	var outpoints []wire.OutPoint
+	for _, utxo := range parentUTXOs {
+		outpoint, err := utxo.outpoint()
+		if err != nil {
+			return nil, err
+		}
+		if reserved[outpoint] {
+			return nil, &CoinControlError{Outpoint: outpoint, Reason: "is reserved"}
+		}
+		utxos = append(utxos, utxo)
+		outpoints = append(outpoints, outpoint)
+	}
+	if len(utxos) == 0 {
+		return nil, fmt.Errorf("parent %s has no unspent output to %s", parentTxid, address)
+	}
+
+	pkScript, err := payToAddressScript(address, c.Net)
+	if err != nil {
+		return nil, err
+	}
+	pKey, _ := btcec.PrivKeyFromBytes(privKey)
+	vsize := keyInputVSize(pKey.PubKey())
+	var inputsVSize, total int64
+	var witness bool
+	for _, utxo := range utxos {
+		inputVSize, err := vsize(utxo.PKScript)
+		if err != nil {
+			return nil, fmt.Errorf("utxo %s:%d: %w", utxo.Hash, utxo.TxIndex, err)
+		}
+		inputsVSize += inputVSize
+		witness = witness || txscript.IsWitnessProgram(utxo.PKScript) || txscript.IsPayToScriptHash(utxo.PKScript)
+		total += utxo.Amount.Int64()
+	}
+	base := TxSizeEstimator{witness: witness}
+	base.AddOutput(pkScript)
+
+	// 자식은 부모와 자식을 합친 크기의 수수료에서 부모가 낸 수수료를 뺀 만큼 낸다.
+	// 자식만으로도 최소 relay fee 는 내야 한다.
+	childVSize := base.VSize() + inputsVSize
+	fee := feeForVSize(feeRate, parentVSize+childVSize) - parentFee
+	if minFee := feeForVSize(MinRelayFeeRate, childVSize); fee < minFee {
+		fee = minFee
+	}
+	output := wire.NewTxOut(total-fee, pkScript)
+	if threshold := dustThreshold(output); output.Value < threshold {
+		return nil, &InsufficientFundsError{Available: total, Required: fee + threshold}
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for i := range outpoints {
+		txIn := wire.NewTxIn(&outpoints[i], nil, nil)
+		txIn.Sequence = RBFSequence
+		tx.AddTxIn(txIn)
+	}
+	tx.AddTxOut(output)
+	if err := signTransaction(tx, utxos, pKey); err != nil {
+		return nil, err
+	}
+	signedHex, err := encodeTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	if c.Reservations != nil {
+		if err := c.Reservations.Reserve(tx.TxHash().String(), outpoints, c.reservationTTL()); err != nil {
+			return nil, err
+		}
+	}
+
+	// 서명이 추정보다 짧을 수 있으므로 실제 크기로 계산한다.
+	packageFeeRate := float64(parentFee+fee) / float64(parentVSize+txVSize(tx))
+	log.Printf("cpfp %s: parent fee %d, child fee %d, package %.3f sat/vB", parentTxid, parentFee, fee, packageFeeRate)
+	return &CPFPTransaction{
+		Tx:             tx,
+		Hex:            signedHex,
+		Parent:         parentTxid,
+		ParentFee:      parentFee,
+		Fee:            fee,
+		PackageFeeRate: packageFeeRate,
+	}, nil
+}
+
+// mempoolFee returns the fee and the vsize of tx, the unconfirmed transaction
+// txid. bitcoind reports them from its mempool, which also works without
+// -txindex. Other backends look up the outputs spent by tx.
+func (c *Client) mempoolFee(ctx context.Context, txid string, tx *wire.MsgTx) (int64, int64, error) {
+	if rpc, ok := c.Backend.(*RPCClient); ok {
+		entry, err := rpc.GetMempoolEntry(ctx, txid)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parent %s is not in the mempool: %w", txid, err)
+		}
+		fee, err := btcutil.NewAmount(entry.Fees.Base)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int64(fee), int64(entry.VSize), nil
+	}
+	inputs, err := c.prevOuts(ctx, tx)
+	if err != nil {
+		return 0, 0, err
+	}
+	fee := -sumTxOut(tx)
+	for _, utxo := range inputs {
+		fee += utxo.Amount.Int64()
+	}
+	return fee, txVSize(tx), nil
+}
+
+// parentUTXOs returns the unspent outputs of parent, the unconfirmed
+// transaction parentTxid, to address. scantxoutset of bitcoind lists only
+// confirmed outputs, so they are looked up with gettxout instead.
+func (c *Client) parentUTXOs(ctx context.Context, address string, parentTxid string, parent *wire.MsgTx) ([]*UTXO, error) {
+	rpc, ok := c.Backend.(*RPCClient)
+	if !ok {
+		unspentTXOs, err := c.ListUTXOs(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		var utxos []*UTXO
+		for _, utxo := range unspentTXOs {
+			if utxo.Hash != parentTxid {
+				continue
+			}
+			if utxo.BlockHeight > 0 {
+				return nil, fmt.Errorf("parent %s is already confirmed", parentTxid)
+			}
+			utxos = append(utxos, utxo)
+		}
+		return utxos, nil
+	}
+
+	pkScript, err := payToAddressScript(address, c.Net)
+	if err != nil {
+		return nil, err
+	}
+	var utxos []*UTXO
+	for i, out := range parent.TxOut {
+		if !bytes.Equal(out.PkScript, pkScript) {
+			continue
+		}
+		txOut, err := rpc.GetTxOut(ctx, parentTxid, i, true)
+		if err != nil {
+			return nil, err
+		}
+		if txOut == nil {
+			// 다른 tx 가 이미 썼다.
+			continue
+		}
+		if txOut.Confirmations > 0 {
+			return nil, fmt.Errorf("parent %s is already confirmed", parentTxid)
+		}
+		utxos = append(utxos, &UTXO{
+			Hash:      parentTxid,
+			TxIndex:   i,
+			Amount:    big.NewInt(out.Value),
+			Spendable: true,
+			PKScript:  out.PkScript,
+		})
+	}
+	return utxos, nil
+}
+
+// SendCPFP creates a child paying for parentTxid and broadcasts it. See
+// CreateCPFPTransaction.
+func (c *Client) SendCPFP(ctx context.Context, address string, parentTxid string, feeRate float64, privKey []byte) (*CPFPTransaction, error) {
+	child, err := c.CreateCPFPTransaction(ctx, address, parentTxid, feeRate, privKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+	if _, err := c.SendRawTransaction(ctx, child.Hex); err != nil {
+		c.releaseReservation(child.Hex)
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
+	}
+	c.markSpent(child.Hex)
+	log.Printf("%s: child of %s, txHash: %s", address, parentTxid, child.Txid())
+	return child, nil
+}
+
+// sumTxOut returns the sum of the outputs of tx.
+func sumTxOut(tx *wire.MsgTx) int64 {
+	var total int64
+	for _, out := range tx.TxOut {
+		total += out.Value
+	}
+	return total
+}
@@ -0,0 +1,222 @@
+package btcw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestClientCreateCPFPTransaction(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	sender, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	senderAddress := sender.GetSegwitAddress(net)
+	wallet, _ := GetWalletFromPrivateKeyString("18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725")
+	uncompressedAddress, _ := GetLegacyAddressFromPubKeyBytes(wallet.SerializePubKeyUncompressed(), net)
+
+	tests := []struct {
+		name    string
+		address string
+	}{
+		{"p2wpkh", wallet.GetSegwitAddress(net)},
+		{"p2tr", wallet.GetTaprootAddress(net)},
+		{"p2pkh uncompressed", uncompressedAddress},
+	}
+	for _, test := range tests {
+		// 1 sat/vB 로 들어온 입금을 10 sat/vB 로 가속한다.
+		backend := newFakeBackend()
+		backend.feeRate = 1
+		backend.utxos[senderAddress] = newTestFundingTx(backend, addressScript(t, senderAddress, net), 50000)
+		client := NewClient(backend, net)
+		client.Reservations = NewMemoryReservationStore()
+
+		parentTxid, err := client.TransferCoin(ctx, senderAddress, test.address, sender.PrivateKeyToBytes(), 20000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent := backend.txs[parentTxid]
+		deposit := &UTXO{Hash: parentTxid, TxIndex: 0, Amount: big.NewInt(20000), Spendable: true}
+		backend.utxos[test.address] = []*UTXO{newTestUTXO(5, 7000, nil), deposit}
+
+		child, err := client.SendCPFP(ctx, test.address, parentTxid, 10, wallet.PrivateKeyToBytes())
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		tx := child.Tx
+		if len(backend.broadcast) != 2 || backend.broadcast[1] != child.Hex {
+			t.Errorf("%s: child should be broadcast", test.name)
+		}
+		if len(tx.TxIn) != 1 || tx.TxIn[0].PreviousOutPoint.Hash != parent.TxHash() || len(tx.TxOut) != 1 {
+			t.Fatalf("%s: child should spend only the deposit", test.name)
+		}
+		if child.ParentFee != 50000-sumTxOut(parent) || child.Fee != 20000-tx.TxOut[0].Value {
+			t.Errorf("%s: unexpected fees %d %d", test.name, child.ParentFee, child.Fee)
+		}
+		// 서명이 추정보다 1 byte 짧으면 package fee rate 가 목표보다 조금 높다.
+		packageVSize := txVSize(parent) + txVSize(tx)
+		if rate := float64(child.ParentFee+child.Fee) / float64(packageVSize); rate != child.PackageFeeRate || rate < 10 || rate > 10.2 {
+			t.Errorf("%s: unexpected package fee rate %f", test.name, child.PackageFeeRate)
+		}
+		verifyTransaction(t, tx, []*UTXO{deposit})
+	}
+}
+
+func TestClientCreateCPFPTransactionErrors(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	address := wallet.GetSegwitAddress(net)
+	toAddress := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
+
+	backend := newFakeBackend()
+	backend.feeRate = 2
+	backend.utxos[address] = newTestFundingTx(backend, addressScript(t, address, net), 30000)
+	client := NewClient(backend, net)
+
+	// 잔돈 output 을 부모로 쓴다.
+	parentTxid, err := client.TransferCoin(ctx, address, toAddress, wallet.PrivateKeyToBytes(), 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change := &UTXO{Hash: parentTxid, TxIndex: 1, Amount: big.NewInt(backend.txs[parentTxid].TxOut[1].Value), Spendable: true}
+	backend.utxos[address] = []*UTXO{change}
+
+	if _, err := client.CreateCPFPTransaction(ctx, address, parentTxid, 2, wallet.PrivateKeyToBytes()); err == nil {
+		t.Errorf("parent already paying the fee rate should fail")
+	}
+	if _, err := client.CreateCPFPTransaction(ctx, address, parentTxid, 200, wallet.PrivateKeyToBytes()); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("expected insufficient funds, got %v", err)
+	}
+	if _, err := client.CreateCPFPTransaction(ctx, toAddress, parentTxid, 10, wallet.PrivateKeyToBytes()); err == nil {
+		t.Errorf("parent without outputs to the address should fail")
+	}
+	change.BlockHeight = 100
+	if _, err := client.CreateCPFPTransaction(ctx, address, parentTxid, 10, wallet.PrivateKeyToBytes()); err == nil {
+		t.Errorf("confirmed parent should fail")
+	}
+	change.BlockHeight = 0
+	if _, err := client.CreateCPFPTransaction(ctx, address, parentTxid, 10, wallet.PrivateKeyToBytes()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientCreateCPFPTransactionRPC(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	sender, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	senderAddress := sender.GetSegwitAddress(net)
+	wallet, _ := GetWalletFromPrivateKeyString("18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725")
+	address := wallet.GetSegwitAddress(net)
+
+	backend := newFakeBackend()
+	backend.feeRate = 1
+	backend.utxos[senderAddress] = newTestFundingTx(backend, addressScript(t, senderAddress, net), 50000)
+	parentTxid, err := NewClient(backend, net).TransferCoin(ctx, senderAddress, address, sender.PrivateKeyToBytes(), 20000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := backend.txs[parentTxid]
+	parentHex, _ := encodeTx(parent)
+	parentFee := 50000 - sumTxOut(parent)
+
+	// txindex 가 없는 노드라서 부모의 input 은 찾을 수 없다.
+	server := newTestRPCServer(t, "user", "pass", func(method string, params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+		var txid string
+		json.Unmarshal(params[0], &txid)
+		if txid != parentTxid {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, "No such mempool transaction. Use -txindex or provide a block hash to enable blockchain transaction queries.")
+		}
+		switch method {
+		case "getrawtransaction":
+			return parentHex, nil
+		case "getmempoolentry":
+			return map[string]interface{}{"vsize": txVSize(parent), "fees": map[string]interface{}{"base": btcutil.Amount(parentFee).ToBTC()}}, nil
+		case "gettxout":
+			var vout int
+			json.Unmarshal(params[1], &vout)
+			if vout != 0 {
+				return nil, nil
+			}
+			return map[string]interface{}{"confirmations": 0, "value": 0.0002}, nil
+		}
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMethodNotFound.Code, "Method not found")
+	})
+	defer server.Close()
+	client := NewClient(NewRPCClient(server.URL, "user", "pass", net), net)
+
+	child, err := client.CreateCPFPTransaction(ctx, address, parentTxid, 10, wallet.PrivateKeyToBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := child.Tx
+	if len(tx.TxIn) != 1 || tx.TxIn[0].PreviousOutPoint.Hash != parent.TxHash() || tx.TxIn[0].PreviousOutPoint.Index != 0 {
+		t.Fatalf("child should spend the deposit, got %+v", tx.TxIn)
+	}
+	if child.ParentFee != parentFee || child.Fee != 20000-tx.TxOut[0].Value || child.PackageFeeRate < 10 {
+		t.Errorf("unexpected fees %d %d %f", child.ParentFee, child.Fee, child.PackageFeeRate)
+	}
+	verifyTransaction(t, tx, []*UTXO{{Hash: parentTxid, Amount: big.NewInt(20000), PKScript: parent.TxOut[0].PkScript}})
+
+	// mempool 에 없는 부모는 가속할 수 없다.
+	if _, err := client.CreateCPFPTransaction(ctx, address, "bc26416ce0facd6733b26f5322b21f834ec9206eea9525ffd44e6c1102810fad", 10, wallet.PrivateKeyToBytes()); err == nil {
+		t.Errorf("unknown parent should fail")
+	}
+}
+
+func TestClientCreateCPFPTransactionBlockCypher(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	sender, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	senderAddress := sender.GetSegwitAddress(net)
+	wallet, _ := GetWalletFromPrivateKeyString("18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725")
+	address := wallet.GetSegwitAddress(net)
+
+	fake := newFakeBackend()
+	fake.feeRate = 1
+	funding := newTestFundingTx(fake, addressScript(t, senderAddress, net), 50000)
+	fake.utxos[senderAddress] = funding
+	parentTxid, err := NewClient(fake, net).TransferCoin(ctx, senderAddress, address, sender.PrivateKeyToBytes(), 20000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 입금은 아직 unconfirmed_txrefs 에만 있다.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/addrs/" + address:
+			fmt.Fprintf(w, `{"address":"%s","hasMore":false,"txrefs":[
+				{"tx_hash":"bc26416ce0facd6733b26f5322b21f834ec9206eea9525ffd44e6c1102810fad","block_height":2866858,"tx_output_n":1,"value":17891,"spent":false}],
+				"unconfirmed_txrefs":[
+				{"tx_hash":"%s","block_height":-1,"tx_input_n":-1,"tx_output_n":0,"value":20000,"spent":false}]}`, address, parentTxid)
+		case "/txs/" + parentTxid, "/txs/" + funding[0].Hash:
+			txHex, _ := encodeTx(fake.txs[strings.TrimPrefix(r.URL.Path, "/txs/")])
+			fmt.Fprintf(w, `{"hex":"%s"}`, txHex)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(&BlockCypherBackend{BaseURL: server.URL}, net)
+
+	child, err := client.CreateCPFPTransaction(ctx, address, parentTxid, 10, wallet.PrivateKeyToBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := fake.txs[parentTxid]
+	if tx := child.Tx; len(tx.TxIn) != 1 || tx.TxIn[0].PreviousOutPoint.Hash != parent.TxHash() || tx.TxIn[0].PreviousOutPoint.Index != 0 {
+		t.Fatalf("child should spend the unconfirmed deposit, got %+v", tx.TxIn)
+	}
+	if child.ParentFee != 50000-sumTxOut(parent) || child.PackageFeeRate < 10 {
+		t.Errorf("unexpected parent fee %d, package %f", child.ParentFee, child.PackageFeeRate)
+	}
+}
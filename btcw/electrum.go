@@ -0,0 +1,595 @@
+package btcw
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ElectrumProtocolVersion is the protocol version sent in server.version.
+const ElectrumProtocolVersion = "1.4"
+
+// ErrElectrumClosed is returned by calls on a closed ElectrumClient.
+var ErrElectrumClosed = errors.New("electrum client is closed")
+
+// ElectrumError is an error returned by an Electrum server.
+type ElectrumError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ElectrumError) Error() string {
+	return fmt.Sprintf("electrum error %d: %s", e.Code, e.Message)
+}
+
+// UnmarshalJSON accepts both the {"code", "message"} object and the plain
+// string some servers send.
+func (e *ElectrumError) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &e.Message)
+	}
+	type electrumError ElectrumError
+	return json.Unmarshal(data, (*electrumError)(e))
+}
+
+// ElectrumClient is a client of the Electrum protocol. It keeps one
+// connection to the server and reconnects on the next call after the
+// connection is lost, or right away if there are subscriptions. It is safe
+// for concurrent use.
+// https://electrumx-spesmilo.readthedocs.io/en/latest/protocol.html
+type ElectrumClient struct {
+	// Addr is the host:port of the server.
+	Addr string
+	// TLSConfig is used for ssl servers, nil means plain tcp.
+	TLSConfig *tls.Config
+	Net       *chaincfg.Params
+	// ClientName is sent in server.version.
+	ClientName  string
+	DialTimeout time.Duration
+	// KeepAliveInterval is the server.ping interval while there are
+	// subscriptions, servers close idle connections.
+	KeepAliveInterval time.Duration
+	// ReconnectInterval is the first delay between reconnect attempts while
+	// there are subscriptions. It doubles up to a minute.
+	ReconnectInterval time.Duration
+
+	id uint64
+
+	mu            sync.Mutex
+	conn          *electrumConn
+	serverVersion []string
+	closed        bool
+
+	subMu             sync.Mutex
+	subscriptions     map[string]*electrumSubscription
+	headersSubscribed bool
+	lastHeader        *ElectrumHeader
+	reconnecting      bool
+	pendingEvents     []ElectrumEvent
+
+	eventsOnce  sync.Once
+	closeOnce   sync.Once
+	events      chan ElectrumEvent
+	eventSignal chan struct{}
+	closedCh    chan struct{}
+}
+
+// NewElectrumClient returns a client for addr. The connection is opened by
+// Connect or the first call.
+func NewElectrumClient(addr string, tlsConfig *tls.Config, net *chaincfg.Params) *ElectrumClient {
+	return &ElectrumClient{
+		Addr:              addr,
+		TLSConfig:         tlsConfig,
+		Net:               net,
+		ClientName:        "btcw",
+		DialTimeout:       10 * time.Second,
+		KeepAliveInterval: time.Minute,
+		ReconnectInterval: time.Second,
+	}
+}
+
+// PinnedCertTLSConfig returns a TLS config that only accepts the server
+// certificate certPEM, e.g. a self signed Electrum server certificate.
+func PinnedCertTLSConfig(certPEM []byte) (*tls.Config, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no certificate in PEM data")
+	}
+	fingerprint := sha256.Sum256(block.Bytes)
+	return PinnedFingerprintTLSConfig(fingerprint[:]), nil
+}
+
+// PinnedFingerprintTLSConfig returns a TLS config that only accepts a server
+// certificate with the SHA-256 fingerprint.
+func PinnedFingerprintTLSConfig(fingerprint []byte) *tls.Config {
+	pin := append([]byte(nil), fingerprint...)
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Electrum servers mostly use self signed certificates, so the chain
+		// is not verified. The leaf certificate must match the pin instead.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("server sent no certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if !bytes.Equal(sum[:], pin) {
+				return fmt.Errorf("server certificate %x does not match the pinned certificate", sum)
+			}
+			return nil
+		},
+	}
+}
+
+// ElectrumScriptHash returns the script hash of an output script used by the
+// blockchain.scripthash methods.
+func ElectrumScriptHash(pkScript []byte) string {
+	hash := sha256.Sum256(pkScript)
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	return hex.EncodeToString(hash[:])
+}
+
+// AddressScriptHash returns the Electrum script hash of address.
+func (c *ElectrumClient) AddressScriptHash(address string) (string, error) {
+	pkScript, err := payToAddressScript(address, c.Net)
+	if err != nil {
+		return "", err
+	}
+	return ElectrumScriptHash(pkScript), nil
+}
+
+// Connect opens the connection and negotiates the protocol version if it is
+// not connected yet.
+func (c *ElectrumClient) Connect(ctx context.Context) error {
+	_, err := c.getConn(ctx)
+	return err
+}
+
+// Close closes the connection and the Events channel. Calls after Close
+// return ErrElectrumClosed.
+func (c *ElectrumClient) Close() error {
+	c.initEvents()
+	c.closeOnce.Do(func() { close(c.closedCh) })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// ServerVersion returns the server software and protocol version of the
+// current connection.
+func (c *ElectrumClient) ServerVersion() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.serverVersion
+}
+
+func (c *ElectrumClient) getConn(ctx context.Context) (*electrumConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, ErrElectrumClosed
+	}
+	if c.conn != nil && !c.conn.isDone() {
+		return c.conn, nil
+	}
+
+	netConn, err := c.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", c.Addr, err)
+	}
+	conn := newElectrumConn(netConn, c.handleNotification, c.connectionLost)
+
+	var version []string
+	params := []interface{}{c.ClientName, ElectrumProtocolVersion}
+	if err := conn.call(ctx, c.nextID(), "server.version", params, &version); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to negotiate protocol version: %w", err)
+	}
+	if err := c.resubscribe(ctx, conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to resubscribe: %w", err)
+	}
+	c.conn = conn
+	c.serverVersion = version
+	go c.keepAlive(conn)
+	return conn, nil
+}
+
+func (c *ElectrumClient) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.DialTimeout}
+	if c.TLSConfig == nil {
+		return dialer.DialContext(ctx, "tcp", c.Addr)
+	}
+	tlsDialer := &tls.Dialer{NetDialer: dialer, Config: c.TLSConfig}
+	return tlsDialer.DialContext(ctx, "tcp", c.Addr)
+}
+
+func (c *ElectrumClient) nextID() uint64 {
+	return atomic.AddUint64(&c.id, 1)
+}
+
+// Call calls method and decodes the result into result, which may be nil.
+func (c *ElectrumClient) Call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	conn, err := c.getConn(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.call(ctx, c.nextID(), method, params, result)
+}
+
+type electrumRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type electrumResponse struct {
+	ID     *uint64         `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *ElectrumError  `json:"error"`
+	// Method and Params are set for notifications.
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// electrumConn is a single connection. Requests and responses are newline
+// delimited JSON-RPC messages, responses are matched to requests by ID.
+type electrumConn struct {
+	net.Conn
+
+	onNotification func(*electrumResponse)
+	onClose        func()
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint64]chan *electrumResponse
+	err     error
+	done    chan struct{}
+}
+
+func newElectrumConn(netConn net.Conn, onNotification func(*electrumResponse), onClose func()) *electrumConn {
+	conn := &electrumConn{
+		Conn:           netConn,
+		onNotification: onNotification,
+		onClose:        onClose,
+		pending:        make(map[uint64]chan *electrumResponse),
+		done:           make(chan struct{}),
+	}
+	go conn.readLoop()
+	return conn
+}
+
+func (conn *electrumConn) isDone() bool {
+	select {
+	case <-conn.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (conn *electrumConn) readLoop() {
+	defer conn.onClose()
+	reader := bufio.NewReader(conn.Conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			conn.fail(err)
+			return
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var response electrumResponse
+		if err := json.Unmarshal(line, &response); err != nil {
+			conn.fail(fmt.Errorf("invalid message from server: %w", err))
+			return
+		}
+		if response.ID == nil {
+			conn.onNotification(&response)
+			continue
+		}
+
+		conn.mu.Lock()
+		ch, ok := conn.pending[*response.ID]
+		delete(conn.pending, *response.ID)
+		conn.mu.Unlock()
+		if ok {
+			ch <- &response
+		}
+	}
+}
+
+// fail closes the connection and fails all pending calls with err.
+func (conn *electrumConn) fail(err error) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.err != nil {
+		return
+	}
+	conn.err = err
+	conn.Conn.Close()
+	for id, ch := range conn.pending {
+		close(ch)
+		delete(conn.pending, id)
+	}
+	close(conn.done)
+}
+
+func (conn *electrumConn) call(ctx context.Context, id uint64, method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	data, err := json.Marshal(&electrumRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	ch := make(chan *electrumResponse, 1)
+	conn.mu.Lock()
+	if conn.err != nil {
+		conn.mu.Unlock()
+		return fmt.Errorf("%s: connection lost: %w", method, conn.err)
+	}
+	conn.pending[id] = ch
+	conn.mu.Unlock()
+
+	conn.writeMu.Lock()
+	deadline, _ := ctx.Deadline()
+	conn.SetWriteDeadline(deadline)
+	_, err = conn.Write(data)
+	conn.writeMu.Unlock()
+	if err != nil {
+		conn.fail(err)
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	select {
+	case response, ok := <-ch:
+		if !ok {
+			return fmt.Errorf("%s: connection lost: %w", method, conn.err)
+		}
+		if response.Error != nil {
+			return fmt.Errorf("%s: %w", method, response.Error)
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("%s: failed to decode result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		conn.mu.Lock()
+		delete(conn.pending, id)
+		conn.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (c *ElectrumClient) Ping(ctx context.Context) error {
+	return c.Call(ctx, "server.ping", nil, nil)
+}
+
+// ElectrumUnspent is an unspent output of blockchain.scripthash.listunspent.
+// Height is 0 for unconfirmed outputs.
+type ElectrumUnspent struct {
+	TxHash string `json:"tx_hash"`
+	TxPos  int    `json:"tx_pos"`
+	Height int64  `json:"height"`
+	Value  int64  `json:"value"`
+}
+
+// ElectrumHistoryItem is a transaction of blockchain.scripthash.get_history.
+// Height is 0 for unconfirmed transactions and -1 if they have unconfirmed
+// inputs. Fee is only set for unconfirmed transactions.
+type ElectrumHistoryItem struct {
+	TxHash string `json:"tx_hash"`
+	Height int64  `json:"height"`
+	Fee    int64  `json:"fee,omitempty"`
+}
+
+// ElectrumBalance is the result of blockchain.scripthash.get_balance.
+type ElectrumBalance struct {
+	Confirmed   int64 `json:"confirmed"`
+	Unconfirmed int64 `json:"unconfirmed"`
+}
+
+// ElectrumHeader is the result of blockchain.headers.subscribe.
+type ElectrumHeader struct {
+	Height int64  `json:"height"`
+	Hex    string `json:"hex"`
+}
+
+func (c *ElectrumClient) ListUnspent(ctx context.Context, scriptHash string) ([]ElectrumUnspent, error) {
+	var result []ElectrumUnspent
+	if err := c.Call(ctx, "blockchain.scripthash.listunspent", []interface{}{scriptHash}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ElectrumClient) GetHistory(ctx context.Context, scriptHash string) ([]ElectrumHistoryItem, error) {
+	var result []ElectrumHistoryItem
+	if err := c.Call(ctx, "blockchain.scripthash.get_history", []interface{}{scriptHash}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ElectrumClient) GetScriptHashBalance(ctx context.Context, scriptHash string) (*ElectrumBalance, error) {
+	var result ElectrumBalance
+	if err := c.Call(ctx, "blockchain.scripthash.get_balance", []interface{}{scriptHash}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetRawTransaction returns the hex of a transaction.
+func (c *ElectrumClient) GetRawTransaction(ctx context.Context, txid string) (string, error) {
+	var result string
+	if err := c.Call(ctx, "blockchain.transaction.get", []interface{}{txid, false}, &result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// BroadcastTransaction broadcasts a signed transaction and returns its txid.
+func (c *ElectrumClient) BroadcastTransaction(ctx context.Context, rawTxHex string) (string, error) {
+	var result string
+	if err := c.Call(ctx, "blockchain.transaction.broadcast", []interface{}{rawTxHex}, &result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// EstimateFeeBTCPerKB returns the fee rate in BTC/kB for confirmation within
+// blocks, or -1 if the server has no estimate.
+func (c *ElectrumClient) EstimateFeeBTCPerKB(ctx context.Context, blocks int) (float64, error) {
+	var result float64
+	if err := c.Call(ctx, "blockchain.estimatefee", []interface{}{blocks}, &result); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+// RelayFee returns the minimum relay fee rate of the server in BTC/kB.
+func (c *ElectrumClient) RelayFee(ctx context.Context) (float64, error) {
+	var result float64
+	if err := c.Call(ctx, "blockchain.relayfee", nil, &result); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+// HeadersSubscribe returns the current chain tip.
+func (c *ElectrumClient) HeadersSubscribe(ctx context.Context) (*ElectrumHeader, error) {
+	var result ElectrumHeader
+	if err := c.Call(ctx, "blockchain.headers.subscribe", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ElectrumClient) ListUTXOs(ctx context.Context, address string) ([]*UTXO, error) {
+	pkScript, err := payToAddressScript(address, c.Net)
+	if err != nil {
+		return nil, err
+	}
+	unspents, err := c.ListUnspent(ctx, ElectrumScriptHash(pkScript))
+	if err != nil {
+		return nil, err
+	}
+
+	utxos := make([]*UTXO, 0, len(unspents))
+	for _, unspent := range unspents {
+		utxo := &UTXO{
+			Hash:      unspent.TxHash,
+			TxIndex:   unspent.TxPos,
+			Amount:    big.NewInt(unspent.Value),
+			Spendable: true,
+			PKScript:  pkScript,
+		}
+		if unspent.Height > 0 {
+			utxo.BlockHeight = unspent.Height
+		}
+		utxos = append(utxos, utxo)
+	}
+	return utxos, nil
+}
+
+func (c *ElectrumClient) GetBalance(ctx context.Context, address string) (*GetBalanceResponse, error) {
+	scriptHash, err := c.AddressScriptHash(address)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := c.GetScriptHashBalance(ctx, scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	history, err := c.GetHistory(ctx, scriptHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var nTx int
+	for _, item := range history {
+		if item.Height > 0 {
+			nTx++
+		}
+	}
+	return &GetBalanceResponse{
+		Address:            address,
+		Balance:            int(balance.Confirmed),
+		UnconfirmedBalance: int(balance.Unconfirmed),
+		FinalBalance:       int(balance.Confirmed + balance.Unconfirmed),
+		NTx:                nTx,
+		UnconfirmedNTx:     len(history) - nTx,
+		FinalNTx:           len(history),
+	}, nil
+}
+
+// EstimateFee returns the fee rate in sat/vB. If the server has no estimate
+// the relay fee is used.
+func (c *ElectrumClient) EstimateFee(ctx context.Context, targetBlocks int) (float64, error) {
+	feeRate, err := c.EstimateFeeBTCPerKB(ctx, targetBlocks)
+	if err != nil {
+		return 0, err
+	}
+	if feeRate <= 0 {
+		feeRate, err = c.RelayFee(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return btcPerKBToSatPerVByte(feeRate), nil
+}
+
+func (c *ElectrumClient) Broadcast(ctx context.Context, rawTxHex string) (string, error) {
+	return c.BroadcastTransaction(ctx, rawTxHex)
+}
+
+func (c *ElectrumClient) GetTransaction(ctx context.Context, txid string) (*wire.MsgTx, error) {
+	rawTx, err := c.GetRawTransaction(ctx, txid)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTxHex(rawTx)
+}
+
+func (c *ElectrumClient) TipHeight(ctx context.Context) (int64, error) {
+	header, err := c.HeadersSubscribe(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return header.Height, nil
+}
@@ -0,0 +1,358 @@
+package btcw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ElectrumEventType is the type of an ElectrumEvent.
+type ElectrumEventType int
+
+const (
+	// ElectrumEventStatus is sent when the status of a subscribed address
+	// changes, i.e. a transaction paying to or spending from it was seen in
+	// the mempool or in a block.
+	ElectrumEventStatus ElectrumEventType = iota
+	// ElectrumEventHeader is sent when a new block arrives.
+	ElectrumEventHeader
+)
+
+func (t ElectrumEventType) String() string {
+	switch t {
+	case ElectrumEventStatus:
+		return "status"
+	case ElectrumEventHeader:
+		return "header"
+	}
+	return "unknown"
+}
+
+// ElectrumEvent is a notification of a subscription.
+type ElectrumEvent struct {
+	Type ElectrumEventType
+	// Address, ScriptHash and Status are set for ElectrumEventStatus. Status
+	// is empty if the address has no history.
+	Address    string
+	ScriptHash string
+	Status     string
+	// Header is set for ElectrumEventHeader.
+	Header *ElectrumHeader
+}
+
+// electrumReconnectTimeout is the timeout of a reconnect attempt, including
+// the protocol negotiation and resubscribing.
+const electrumReconnectTimeout = 30 * time.Second
+
+type electrumSubscription struct {
+	address    string
+	scriptHash string
+	status     string
+	// known is false until the first status is received.
+	known bool
+}
+
+// Events returns the channel of subscription events. Events are queued until
+// they are received, so the channel should be read while there are
+// subscriptions. It is closed by Close.
+func (c *ElectrumClient) Events() <-chan ElectrumEvent {
+	c.initEvents()
+	return c.events
+}
+
+// SubscribeAddress subscribes to status changes of address and returns the
+// current status, which is empty if the address has no history. The
+// subscription is renewed after a reconnect, and an ElectrumEventStatus is
+// sent if the status changed while disconnected.
+func (c *ElectrumClient) SubscribeAddress(ctx context.Context, address string) (string, error) {
+	scriptHash, err := c.AddressScriptHash(address)
+	if err != nil {
+		return "", err
+	}
+	c.initEvents()
+
+	c.subMu.Lock()
+	if c.subscriptions == nil {
+		c.subscriptions = make(map[string]*electrumSubscription)
+	}
+	sub, subscribed := c.subscriptions[scriptHash]
+	if !subscribed {
+		sub = &electrumSubscription{address: address, scriptHash: scriptHash}
+		c.subscriptions[scriptHash] = sub
+	}
+	c.subMu.Unlock()
+
+	var status *string
+	if err := c.Call(ctx, "blockchain.scripthash.subscribe", []interface{}{scriptHash}, &status); err != nil {
+		if !subscribed {
+			c.subMu.Lock()
+			delete(c.subscriptions, scriptHash)
+			c.subMu.Unlock()
+		}
+		return "", err
+	}
+
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	sub.status = electrumStatus(status)
+	sub.known = true
+	return sub.status, nil
+}
+
+// UnsubscribeAddress stops the status notifications of address.
+func (c *ElectrumClient) UnsubscribeAddress(ctx context.Context, address string) error {
+	scriptHash, err := c.AddressScriptHash(address)
+	if err != nil {
+		return err
+	}
+	c.subMu.Lock()
+	delete(c.subscriptions, scriptHash)
+	c.subMu.Unlock()
+
+	return c.Call(ctx, "blockchain.scripthash.unsubscribe", []interface{}{scriptHash}, nil)
+}
+
+// SubscribeHeaders subscribes to new blocks and returns the current tip. The
+// subscription is renewed after a reconnect, and an ElectrumEventHeader is
+// sent if the tip changed while disconnected.
+func (c *ElectrumClient) SubscribeHeaders(ctx context.Context) (*ElectrumHeader, error) {
+	c.initEvents()
+	c.subMu.Lock()
+	subscribed := c.headersSubscribed
+	c.headersSubscribed = true
+	c.subMu.Unlock()
+
+	header, err := c.HeadersSubscribe(ctx)
+	if err != nil {
+		if !subscribed {
+			c.subMu.Lock()
+			c.headersSubscribed = false
+			c.subMu.Unlock()
+		}
+		return nil, err
+	}
+
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	c.lastHeader = header
+	return header, nil
+}
+
+func (c *ElectrumClient) hasSubscriptions() bool {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	return len(c.subscriptions) > 0 || c.headersSubscribed
+}
+
+func electrumStatus(status *string) string {
+	if status == nil {
+		return ""
+	}
+	return *status
+}
+
+func (c *ElectrumClient) handleNotification(notification *electrumResponse) {
+	var params []json.RawMessage
+	if err := json.Unmarshal(notification.Params, &params); err != nil {
+		return
+	}
+
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	switch notification.Method {
+	case "blockchain.scripthash.subscribe":
+		if len(params) != 2 {
+			return
+		}
+		var scriptHash string
+		var status *string
+		if json.Unmarshal(params[0], &scriptHash) != nil || json.Unmarshal(params[1], &status) != nil {
+			return
+		}
+		sub, ok := c.subscriptions[scriptHash]
+		if !ok {
+			return
+		}
+		sub.status = electrumStatus(status)
+		sub.known = true
+		c.emitLocked(ElectrumEvent{Type: ElectrumEventStatus, Address: sub.address, ScriptHash: scriptHash, Status: sub.status})
+
+	case "blockchain.headers.subscribe":
+		// TipHeight also subscribes, those notifications are ignored.
+		if len(params) != 1 || !c.headersSubscribed {
+			return
+		}
+		var header ElectrumHeader
+		if json.Unmarshal(params[0], &header) != nil {
+			return
+		}
+		c.lastHeader = &header
+		c.emitLocked(ElectrumEvent{Type: ElectrumEventHeader, Header: &header})
+	}
+}
+
+// resubscribe renews the subscriptions on a new connection.
+func (c *ElectrumClient) resubscribe(ctx context.Context, conn *electrumConn) error {
+	c.subMu.Lock()
+	subs := make([]*electrumSubscription, 0, len(c.subscriptions))
+	for _, sub := range c.subscriptions {
+		// the others are still being subscribed by SubscribeAddress
+		if sub.known {
+			subs = append(subs, sub)
+		}
+	}
+	headersSubscribed := c.headersSubscribed
+	c.subMu.Unlock()
+
+	for _, sub := range subs {
+		var status *string
+		if err := conn.call(ctx, c.nextID(), "blockchain.scripthash.subscribe", []interface{}{sub.scriptHash}, &status); err != nil {
+			return err
+		}
+		c.subMu.Lock()
+		if sub.status != electrumStatus(status) {
+			c.emitLocked(ElectrumEvent{Type: ElectrumEventStatus, Address: sub.address, ScriptHash: sub.scriptHash, Status: electrumStatus(status)})
+		}
+		sub.status = electrumStatus(status)
+		c.subMu.Unlock()
+	}
+
+	if headersSubscribed {
+		var header ElectrumHeader
+		if err := conn.call(ctx, c.nextID(), "blockchain.headers.subscribe", nil, &header); err != nil {
+			return err
+		}
+		c.subMu.Lock()
+		if c.lastHeader != nil && *c.lastHeader != header {
+			c.emitLocked(ElectrumEvent{Type: ElectrumEventHeader, Header: &header})
+		}
+		c.lastHeader = &header
+		c.subMu.Unlock()
+	}
+	return nil
+}
+
+// connectionLost starts reconnecting if there are subscriptions.
+func (c *ElectrumClient) connectionLost() {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	if c.reconnecting || (len(c.subscriptions) == 0 && !c.headersSubscribed) {
+		return
+	}
+	c.reconnecting = true
+	go c.reconnectLoop()
+}
+
+func (c *ElectrumClient) reconnectLoop() {
+	c.initEvents()
+	delay := c.ReconnectInterval
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	for {
+		select {
+		case <-time.After(delay):
+		case <-c.closedCh:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), electrumReconnectTimeout)
+		_, err := c.getConn(ctx)
+		cancel()
+		if err == nil || errors.Is(err, ErrElectrumClosed) {
+			break
+		}
+		if delay *= 2; delay > time.Minute {
+			delay = time.Minute
+		}
+	}
+
+	c.subMu.Lock()
+	c.reconnecting = false
+	c.subMu.Unlock()
+
+	// The new connection may have been lost before reconnecting was reset.
+	c.mu.Lock()
+	lost := !c.closed && (c.conn == nil || c.conn.isDone())
+	c.mu.Unlock()
+	if lost {
+		c.connectionLost()
+	}
+}
+
+// keepAlive pings the server while conn is open and there are subscriptions.
+func (c *ElectrumClient) keepAlive(conn *electrumConn) {
+	if c.KeepAliveInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.KeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-conn.done:
+			return
+		case <-ticker.C:
+		}
+		if !c.hasSubscriptions() {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), c.KeepAliveInterval)
+		err := conn.call(ctx, c.nextID(), "server.ping", nil, nil)
+		cancel()
+		if err != nil {
+			conn.fail(err)
+			return
+		}
+	}
+}
+
+func (c *ElectrumClient) initEvents() {
+	c.eventsOnce.Do(func() {
+		c.events = make(chan ElectrumEvent)
+		c.eventSignal = make(chan struct{}, 1)
+		c.closedCh = make(chan struct{})
+		go c.deliverEvents()
+	})
+}
+
+// emitLocked queues event, c.subMu must be held.
+func (c *ElectrumClient) emitLocked(event ElectrumEvent) {
+	c.pendingEvents = append(c.pendingEvents, event)
+	select {
+	case c.eventSignal <- struct{}{}:
+	default:
+	}
+}
+
+// deliverEvents sends the queued events to the Events channel, so that a slow
+// reader does not block the connection.
+func (c *ElectrumClient) deliverEvents() {
+	defer close(c.events)
+	for {
+		select {
+		case <-c.eventSignal:
+		case <-c.closedCh:
+			return
+		}
+
+		for {
+			c.subMu.Lock()
+			if len(c.pendingEvents) == 0 {
+				c.subMu.Unlock()
+				break
+			}
+			event := c.pendingEvents[0]
+			c.pendingEvents = c.pendingEvents[1:]
+			c.subMu.Unlock()
+
+			select {
+			case c.events <- event:
+			case <-c.closedCh:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package btcw
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func receiveElectrumEvent(t *testing.T, events <-chan ElectrumEvent) ElectrumEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return ElectrumEvent{}
+}
+
+func TestElectrumSubscriptions(t *testing.T) {
+	chainParams := &chaincfg.TestNet3Params
+	address := "tb1qz40mujlemrru7t8t3yn3u5v3e9htmu5kektgme"
+	pkScript, _ := payToAddressScript(address, chainParams)
+	scriptHash := ElectrumScriptHash(pkScript)
+
+	var mu sync.Mutex
+	statuses := map[string]string{}
+	header := ElectrumHeader{Height: 100, Hex: "00"}
+	var subscribeCalls int
+
+	server := newElectrumTestServer(t, nil, func(conn net.Conn, req *electrumTestRequest) (interface{}, *ElectrumError) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Method {
+		case "blockchain.scripthash.subscribe":
+			subscribeCalls++
+			var param string
+			json.Unmarshal(req.Params[0], &param)
+			if status, ok := statuses[param]; ok {
+				return status, nil
+			}
+			return nil, nil
+		case "blockchain.headers.subscribe":
+			return header, nil
+		}
+		return nil, nil
+	})
+
+	ctx := context.Background()
+	client := NewElectrumClient(server.Addr(), nil, chainParams)
+	client.ReconnectInterval = 10 * time.Millisecond
+	defer client.Close()
+	events := client.Events()
+
+	status, err := client.SubscribeAddress(ctx, address)
+	if err != nil || status != "" {
+		t.Fatalf("unexpected status %q %v", status, err)
+	}
+	tip, err := client.SubscribeHeaders(ctx)
+	if err != nil || tip.Height != 100 {
+		t.Fatalf("unexpected tip %+v %v", tip, err)
+	}
+
+	// 주소로 입금되면 status 가 바뀐다.
+	mu.Lock()
+	statuses[scriptHash] = "aaaa"
+	mu.Unlock()
+	server.notify("blockchain.scripthash.subscribe", scriptHash, "aaaa")
+	event := receiveElectrumEvent(t, events)
+	if event.Type != ElectrumEventStatus || event.Address != address || event.Status != "aaaa" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	server.notify("blockchain.headers.subscribe", ElectrumHeader{Height: 101, Hex: "01"})
+	event = receiveElectrumEvent(t, events)
+	if event.Type != ElectrumEventHeader || event.Header.Height != 101 {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	// 연결이 끊긴 동안 바뀐 status 와 block 은 다시 subscribe 하면서 event 로 보낸다.
+	mu.Lock()
+	statuses[scriptHash] = "bbbb"
+	header = ElectrumHeader{Height: 102, Hex: "02"}
+	mu.Unlock()
+	server.dropAll()
+
+	received := map[ElectrumEventType]ElectrumEvent{}
+	for i := 0; i < 2; i++ {
+		event := receiveElectrumEvent(t, events)
+		received[event.Type] = event
+	}
+	if received[ElectrumEventStatus].Status != "bbbb" {
+		t.Errorf("unexpected status event %+v", received[ElectrumEventStatus])
+	}
+	if received[ElectrumEventHeader].Header == nil || received[ElectrumEventHeader].Header.Height != 102 {
+		t.Errorf("unexpected header event %+v", received[ElectrumEventHeader])
+	}
+	if conns, _ := server.stats(); conns != 2 {
+		t.Errorf("expected 2 connections, got %d", conns)
+	}
+	mu.Lock()
+	if subscribeCalls != 2 {
+		t.Errorf("expected 2 subscribe calls, got %d", subscribeCalls)
+	}
+	mu.Unlock()
+
+	// 다시 연결한 후의 notification 도 받는다.
+	server.notify("blockchain.scripthash.subscribe", scriptHash, "cccc")
+	event = receiveElectrumEvent(t, events)
+	if event.Status != "cccc" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	client.Close()
+	if _, ok := <-events; ok {
+		t.Errorf("events should be closed by Close")
+	}
+}
+
+func TestElectrumTipHeightDoesNotSendEvents(t *testing.T) {
+	server := newElectrumTestServer(t, nil, func(conn net.Conn, req *electrumTestRequest) (interface{}, *ElectrumError) {
+		return ElectrumHeader{Height: 100}, nil
+	})
+
+	client := NewElectrumClient(server.Addr(), nil, &chaincfg.TestNet3Params)
+	defer client.Close()
+	events := client.Events()
+
+	if _, err := client.TipHeight(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	server.notify("blockchain.headers.subscribe", ElectrumHeader{Height: 101})
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
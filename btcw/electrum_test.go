@@ -0,0 +1,327 @@
+package btcw
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+type electrumTestRequest struct {
+	ID     uint64            `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// electrumTestServer is a fake Electrum server. handle returns the result or
+// the error of a call, connections are closed if it returns errDropConn.
+type electrumTestServer struct {
+	listener net.Listener
+	handle   func(conn net.Conn, req *electrumTestRequest) (interface{}, *ElectrumError)
+
+	mu       sync.Mutex
+	conns    int
+	versions [][]json.RawMessage
+	// open is the write lock of each open connection.
+	open map[net.Conn]*sync.Mutex
+}
+
+func newElectrumTestServer(t *testing.T, tlsConfig *tls.Config, handle func(conn net.Conn, req *electrumTestRequest) (interface{}, *ElectrumError)) *electrumTestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	server := &electrumTestServer{listener: listener, handle: handle, open: make(map[net.Conn]*sync.Mutex)}
+	t.Cleanup(func() { listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (s *electrumTestServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// stats returns the number of connections and server.version calls.
+func (s *electrumTestServer) stats() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns, len(s.versions)
+}
+
+func (s *electrumTestServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		writeMu := &sync.Mutex{}
+		s.mu.Lock()
+		s.conns++
+		s.open[conn] = writeMu
+		s.mu.Unlock()
+		go s.serveConn(conn, writeMu)
+	}
+}
+
+// notify sends a notification to all open connections.
+func (s *electrumTestServer) notify(method string, params ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, writeMu := range s.open {
+		writeMu.Lock()
+		json.NewEncoder(conn).Encode(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+		writeMu.Unlock()
+	}
+}
+
+// dropAll closes all open connections.
+func (s *electrumTestServer) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.open {
+		conn.Close()
+	}
+}
+
+func (s *electrumTestServer) serveConn(conn net.Conn, writeMu *sync.Mutex) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.open, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var req electrumTestRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			return
+		}
+		if req.Method == "server.version" {
+			s.mu.Lock()
+			s.versions = append(s.versions, req.Params)
+			s.mu.Unlock()
+			writeMu.Lock()
+			json.NewEncoder(conn).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": []string{"ElectrumX 1.16.0", "1.4"}})
+			writeMu.Unlock()
+			continue
+		}
+		// 요청마다 goroutine 에서 응답하므로 응답 순서가 요청 순서와 다를 수 있다.
+		go func() {
+			result, rpcErr := s.handle(conn, &req)
+			if rpcErr == errDropConn {
+				conn.Close()
+				return
+			}
+			response := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+			if rpcErr != nil {
+				response["error"] = rpcErr
+			} else {
+				response["result"] = result
+			}
+			writeMu.Lock()
+			json.NewEncoder(conn).Encode(response)
+			writeMu.Unlock()
+		}()
+	}
+}
+
+var errDropConn = &ElectrumError{Message: "drop connection"}
+
+func TestElectrumScriptHash(t *testing.T) {
+	// https://electrumx-spesmilo.readthedocs.io/en/latest/protocol-basics.html#script-hashes
+	client := NewElectrumClient("", nil, &chaincfg.MainNetParams)
+	scriptHash, err := client.AddressScriptHash("1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scriptHash != "8b01df4e368ea28f8dc0423bcf7a4923e3a12d307c875e47a0cfbf90b5c39161" {
+		t.Errorf("unexpected script hash %s", scriptHash)
+	}
+}
+
+func TestElectrumClient(t *testing.T) {
+	chainParams := &chaincfg.TestNet3Params
+	address := "tb1qz40mujlemrru7t8t3yn3u5v3e9htmu5kektgme"
+	pkScript, _ := payToAddressScript(address, chainParams)
+	scriptHash := ElectrumScriptHash(pkScript)
+
+	server := newElectrumTestServer(t, nil, func(conn net.Conn, req *electrumTestRequest) (interface{}, *ElectrumError) {
+		switch req.Method {
+		case "blockchain.scripthash.listunspent":
+			var param string
+			json.Unmarshal(req.Params[0], &param)
+			if param != scriptHash {
+				return nil, &ElectrumError{Code: 1, Message: "unknown script hash"}
+			}
+			// 늦게 응답해서 다른 요청의 응답이 먼저 도착하도록 한다.
+			time.Sleep(50 * time.Millisecond)
+			return []ElectrumUnspent{
+				{TxHash: "bc26416ce0facd6733b26f5322b21f834ec9206eea9525ffd44e6c1102810fad", TxPos: 1, Height: 2866858, Value: 17891},
+				{TxHash: "ae2db75b9d7bfb9bc3c39e238e1479df3021b206039f67528024ab123990feb4", TxPos: 0, Height: 0, Value: 15224},
+			}, nil
+		case "blockchain.scripthash.get_balance":
+			return ElectrumBalance{Confirmed: 17891, Unconfirmed: 15224}, nil
+		case "blockchain.scripthash.get_history":
+			return []ElectrumHistoryItem{{TxHash: "bc26", Height: 2866858}, {TxHash: "ae2d", Height: 0, Fee: 200}}, nil
+		case "blockchain.estimatefee":
+			return -1, nil
+		case "blockchain.relayfee":
+			return 0.00001, nil
+		case "blockchain.headers.subscribe":
+			return ElectrumHeader{Height: 2866877}, nil
+		case "blockchain.transaction.broadcast":
+			return nil, &ElectrumError{Code: 1, Message: "the transaction was rejected by network rules."}
+		}
+		return nil, &ElectrumError{Code: -32601, Message: "unknown method"}
+	})
+
+	ctx := context.Background()
+	client := NewElectrumClient(server.Addr(), nil, chainParams)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	var utxos []*UTXO
+	var utxosErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		utxos, utxosErr = client.ListUTXOs(ctx, address)
+	}()
+	height, err := client.TipHeight(ctx)
+	if err != nil || height != 2866877 {
+		t.Errorf("unexpected tip height %d %v", height, err)
+	}
+	wg.Wait()
+	if utxosErr != nil {
+		t.Fatal(utxosErr)
+	}
+	if len(utxos) != 2 || utxos[0].Amount.Int64() != 17891 || string(utxos[0].PKScript) != string(pkScript) {
+		t.Errorf("unexpected utxos %+v", utxos)
+	}
+
+	balance, err := client.GetBalance(ctx, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.FinalBalance != 33115 || balance.NTx != 1 || balance.FinalNTx != 2 {
+		t.Errorf("unexpected balance %+v", balance)
+	}
+
+	// estimatefee 가 -1 이면 relayfee 를 사용한다. 0.00001 BTC/kB = 1 sat/vB
+	feeRate, err := client.EstimateFee(ctx, 6)
+	if err != nil || feeRate != 1 {
+		t.Errorf("unexpected fee rate %v %v", feeRate, err)
+	}
+
+	_, err = client.Broadcast(ctx, "00")
+	var electrumErr *ElectrumError
+	if !errors.As(err, &electrumErr) || electrumErr.Code != 1 {
+		t.Errorf("expected an ElectrumError, got %v", err)
+	}
+
+	// 모든 요청은 server.version 후 하나의 연결로 보낸다.
+	if conns, versions := server.stats(); conns != 1 || versions != 1 {
+		t.Errorf("expected 1 connection, got %d", conns)
+	}
+	if version := client.ServerVersion(); len(version) != 2 || version[1] != "1.4" {
+		t.Errorf("unexpected server version %v", version)
+	}
+}
+
+func TestElectrumClientReconnect(t *testing.T) {
+	var drop sync.Once
+	server := newElectrumTestServer(t, nil, func(conn net.Conn, req *electrumTestRequest) (interface{}, *ElectrumError) {
+		if req.Method == "server.ping" {
+			var dropped bool
+			drop.Do(func() { dropped = true })
+			if dropped {
+				return nil, errDropConn
+			}
+		}
+		return nil, nil
+	})
+
+	ctx := context.Background()
+	client := NewElectrumClient(server.Addr(), nil, &chaincfg.TestNet3Params)
+	defer client.Close()
+
+	if err := client.Ping(ctx); err == nil {
+		t.Errorf("ping should fail when the server drops the connection")
+	}
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("client should reconnect: %v", err)
+	}
+	if conns, versions := server.stats(); conns != 2 || versions != 2 {
+		t.Errorf("expected 2 connections, got %d", conns)
+	}
+
+	client.Close()
+	if err := client.Ping(ctx); err != ErrElectrumClosed {
+		t.Errorf("expected ErrElectrumClosed, got %v", err)
+	}
+}
+
+func newTestCertificate(t *testing.T) (tls.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, certPEM
+}
+
+func TestElectrumClientPinnedTLS(t *testing.T) {
+	cert, certPEM := newTestCertificate(t)
+	server := newElectrumTestServer(t, &tls.Config{Certificates: []tls.Certificate{cert}}, func(conn net.Conn, req *electrumTestRequest) (interface{}, *ElectrumError) {
+		return nil, nil
+	})
+	ctx := context.Background()
+
+	tlsConfig, err := PinnedCertTLSConfig(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewElectrumClient(server.Addr(), tlsConfig, &chaincfg.TestNet3Params)
+	defer client.Close()
+	if err := client.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	otherFingerprint := sha256.Sum256([]byte("other certificate"))
+	other := NewElectrumClient(server.Addr(), PinnedFingerprintTLSConfig(otherFingerprint[:]), &chaincfg.TestNet3Params)
+	defer other.Close()
+	if err := other.Ping(ctx); err == nil {
+		t.Errorf("connecting to a server with another certificate should fail")
+	}
+}
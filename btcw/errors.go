@@ -0,0 +1,84 @@
+package btcw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInsufficientFunds is matched by an InsufficientFundsError.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrDust is matched by a DustError.
+	ErrDust = errors.New("output amount is dust")
+	// ErrBackendUnavailable is returned when no chain backend could serve a
+	// request, e.g. on connection errors, timeouts and rate limits.
+	ErrBackendUnavailable = errors.New("chain backend unavailable")
+	// ErrInvalidAddress is returned for addresses that can not be decoded or
+	// are not for the network of the client.
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrTxTooLarge is returned when a transaction would weigh more than the
+	// standard weight and would not be relayed.
+	ErrTxTooLarge = errors.New("transaction is too large")
+)
+
+// InsufficientFundsError is returned when the UTXOs can not pay the amount
+// and the fee.
+type InsufficientFundsError struct {
+	// Available is the sum of the UTXOs and Required the amount plus fee, in
+	// satoshis.
+	Available int64
+	Required  int64
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("%v: available %d, required %d", ErrInsufficientFunds, e.Available, e.Required)
+}
+
+func (e *InsufficientFundsError) Is(target error) bool {
+	return target == ErrInsufficientFunds
+}
+
+// DustError is returned when an output would be below the dust threshold and
+// would not be relayed.
+type DustError struct {
+	Amount    int64
+	Threshold int64
+}
+
+func (e *DustError) Error() string {
+	return fmt.Sprintf("%v: %d is below the dust threshold %d", ErrDust, e.Amount, e.Threshold)
+}
+
+func (e *DustError) Is(target error) bool {
+	return target == ErrDust
+}
+
+// backendUnavailableError wraps a backend error that matches
+// ErrBackendUnavailable while keeping the original error for errors.As.
+type backendUnavailableError struct {
+	err error
+}
+
+func (e *backendUnavailableError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrBackendUnavailable, e.err)
+}
+
+func (e *backendUnavailableError) Is(target error) bool {
+	return target == ErrBackendUnavailable
+}
+
+func (e *backendUnavailableError) Unwrap() error {
+	return e.err
+}
+
+// wrapBackendError makes failures of a backend match ErrBackendUnavailable.
+// Context errors and answers of the backend, e.g. a rejected transaction, are
+// returned as they are.
+func wrapBackendError(err error) error {
+	if err == nil || isRejection(err) || errors.Is(err, ErrBackendUnavailable) ||
+		errors.Is(err, ErrInvalidAddress) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return &backendUnavailableError{err: err}
+}
@@ -0,0 +1,298 @@
+package btcw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// EsploraTxStatus is the confirmation status of a transaction.
+type EsploraTxStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight int64  `json:"block_height,omitempty"`
+	BlockHash   string `json:"block_hash,omitempty"`
+	BlockTime   int64  `json:"block_time,omitempty"`
+}
+
+// EsploraUTXO is an unspent output of GET /address/:address/utxo.
+type EsploraUTXO struct {
+	TxID   string          `json:"txid"`
+	Vout   int             `json:"vout"`
+	Status EsploraTxStatus `json:"status"`
+	Value  int64           `json:"value"`
+}
+
+// EsploraMerkleProof is the merkle inclusion proof of a transaction.
+type EsploraMerkleProof struct {
+	BlockHeight int64    `json:"block_height"`
+	Merkle      []string `json:"merkle"`
+	Pos         int      `json:"pos"`
+}
+
+// EsploraAddressStats is the funded and spent totals of an address.
+type EsploraAddressStats struct {
+	FundedTxoCount int   `json:"funded_txo_count"`
+	FundedTxoSum   int64 `json:"funded_txo_sum"`
+	SpentTxoCount  int   `json:"spent_txo_count"`
+	SpentTxoSum    int64 `json:"spent_txo_sum"`
+	TxCount        int   `json:"tx_count"`
+}
+
+// EsploraAddress is the response of GET /address/:address.
+type EsploraAddress struct {
+	Address      string              `json:"address"`
+	ChainStats   EsploraAddressStats `json:"chain_stats"`
+	MempoolStats EsploraAddressStats `json:"mempool_stats"`
+}
+
+// EsploraBackend is a ChainBackend for the Esplora REST API used by
+// blockstream.info and mempool.space.
+// https://github.com/Blockstream/esplora/blob/master/API.md
+type EsploraBackend struct {
+	// BaseURL is the API URL, e.g. https://blockstream.info/testnet/api
+	BaseURL    string
+	Net        *chaincfg.Params
+	HTTPClient *http.Client
+}
+
+// NewEsploraBackend returns a backend for the Esplora API at baseURL.
+func NewEsploraBackend(baseURL string, net *chaincfg.Params) *EsploraBackend {
+	return &EsploraBackend{BaseURL: strings.TrimSuffix(baseURL, "/"), Net: net, HTTPClient: http.DefaultClient}
+}
+
+// NewBlockstreamBackend returns a backend for blockstream.info.
+func NewBlockstreamBackend(net *chaincfg.Params) (*EsploraBackend, error) {
+	baseURL := "https://blockstream.info/"
+	switch net.Net {
+	case wire.MainNet:
+		baseURL += "api"
+	case wire.TestNet3:
+		baseURL += "testnet/api"
+	case chaincfg.SigNetParams.Net:
+		baseURL += "signet/api"
+	default:
+		return nil, fmt.Errorf("blockstream does not support network %s", net.Name)
+	}
+	return NewEsploraBackend(baseURL, net), nil
+}
+
+// NewMempoolSpaceBackend returns a backend for mempool.space, which also
+// supports testnet4.
+func NewMempoolSpaceBackend(net *chaincfg.Params) (*EsploraBackend, error) {
+	baseURL := "https://mempool.space/"
+	switch net.Net {
+	case wire.MainNet:
+		baseURL += "api"
+	case wire.TestNet3:
+		baseURL += "testnet/api"
+	case TestNet4Wire:
+		baseURL += "testnet4/api"
+	case chaincfg.SigNetParams.Net:
+		baseURL += "signet/api"
+	default:
+		return nil, fmt.Errorf("mempool.space does not support network %s", net.Name)
+	}
+	return NewEsploraBackend(baseURL, net), nil
+}
+
+func (b *EsploraBackend) GetAddress(ctx context.Context, address string) (*EsploraAddress, error) {
+	var result EsploraAddress
+	if err := b.getJSON(ctx, "/address/"+url.PathEscape(address), &result); err != nil {
+		return nil, fmt.Errorf("failed to get address %s: %w", address, err)
+	}
+	return &result, nil
+}
+
+func (b *EsploraBackend) AddressUTXOs(ctx context.Context, address string) ([]EsploraUTXO, error) {
+	var result []EsploraUTXO
+	if err := b.getJSON(ctx, "/address/"+url.PathEscape(address)+"/utxo", &result); err != nil {
+		return nil, fmt.Errorf("failed to get UTXOs of %s: %w", address, err)
+	}
+	return result, nil
+}
+
+func (b *EsploraBackend) GetTxHex(ctx context.Context, txid string) (string, error) {
+	body, err := b.do(ctx, http.MethodGet, "/tx/"+url.PathEscape(txid)+"/hex", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get transaction %s: %w", txid, err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (b *EsploraBackend) GetTxStatus(ctx context.Context, txid string) (*EsploraTxStatus, error) {
+	var result EsploraTxStatus
+	if err := b.getJSON(ctx, "/tx/"+url.PathEscape(txid)+"/status", &result); err != nil {
+		return nil, fmt.Errorf("failed to get status of %s: %w", txid, err)
+	}
+	return &result, nil
+}
+
+// GetMerkleProof returns the merkle proof of a confirmed transaction.
+func (b *EsploraBackend) GetMerkleProof(ctx context.Context, txid string) (*EsploraMerkleProof, error) {
+	var result EsploraMerkleProof
+	if err := b.getJSON(ctx, "/tx/"+url.PathEscape(txid)+"/merkle-proof", &result); err != nil {
+		return nil, fmt.Errorf("failed to get merkle proof of %s: %w", txid, err)
+	}
+	return &result, nil
+}
+
+// FeeEstimates returns the fee rates in sat/vB by confirmation target.
+func (b *EsploraBackend) FeeEstimates(ctx context.Context) (map[int]float64, error) {
+	var result map[string]float64
+	if err := b.getJSON(ctx, "/fee-estimates", &result); err != nil {
+		return nil, fmt.Errorf("failed to get fee estimates: %w", err)
+	}
+	estimates := make(map[int]float64, len(result))
+	for target, feeRate := range result {
+		blocks, err := strconv.Atoi(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fee estimate target %q", target)
+		}
+		estimates[blocks] = feeRate
+	}
+	return estimates, nil
+}
+
+func (b *EsploraBackend) ListUTXOs(ctx context.Context, address string) ([]*UTXO, error) {
+	pkScript, err := payToAddressScript(address, b.Net)
+	if err != nil {
+		return nil, err
+	}
+	esploraUTXOs, err := b.AddressUTXOs(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	utxos := make([]*UTXO, 0, len(esploraUTXOs))
+	for _, esploraUTXO := range esploraUTXOs {
+		utxos = append(utxos, &UTXO{
+			Hash:        esploraUTXO.TxID,
+			TxIndex:     esploraUTXO.Vout,
+			Amount:      big.NewInt(esploraUTXO.Value),
+			Spendable:   true,
+			PKScript:    pkScript,
+			BlockHeight: esploraUTXO.Status.BlockHeight,
+		})
+	}
+	return utxos, nil
+}
+
+func (b *EsploraBackend) GetBalance(ctx context.Context, address string) (*GetBalanceResponse, error) {
+	result, err := b.GetAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	chain, mempool := result.ChainStats, result.MempoolStats
+	balance := chain.FundedTxoSum - chain.SpentTxoSum
+	unconfirmedBalance := mempool.FundedTxoSum - mempool.SpentTxoSum
+	return &GetBalanceResponse{
+		Address:            address,
+		TotalReceived:      int(chain.FundedTxoSum + mempool.FundedTxoSum),
+		TotalSent:          int(chain.SpentTxoSum + mempool.SpentTxoSum),
+		Balance:            int(balance),
+		UnconfirmedBalance: int(unconfirmedBalance),
+		FinalBalance:       int(balance + unconfirmedBalance),
+		NTx:                chain.TxCount,
+		UnconfirmedNTx:     mempool.TxCount,
+		FinalNTx:           chain.TxCount + mempool.TxCount,
+	}, nil
+}
+
+// EstimateFee returns the estimate of the largest target not above
+// targetBlocks, or of the smallest target if there is none.
+func (b *EsploraBackend) EstimateFee(ctx context.Context, targetBlocks int) (float64, error) {
+	estimates, err := b.FeeEstimates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(estimates) == 0 {
+		return 0, fmt.Errorf("no fee estimates")
+	}
+
+	targets := make([]int, 0, len(estimates))
+	for target := range estimates {
+		targets = append(targets, target)
+	}
+	sort.Ints(targets)
+
+	best := targets[0]
+	for _, target := range targets {
+		if target > targetBlocks {
+			break
+		}
+		best = target
+	}
+	return estimates[best], nil
+}
+
+func (b *EsploraBackend) Broadcast(ctx context.Context, rawTxHex string) (string, error) {
+	body, err := b.do(ctx, http.MethodPost, "/tx", strings.NewReader(rawTxHex))
+	if err != nil {
+		return "", fmt.Errorf("failed to send raw transaction: %w", err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (b *EsploraBackend) GetTransaction(ctx context.Context, txid string) (*wire.MsgTx, error) {
+	txHex, err := b.GetTxHex(ctx, txid)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTxHex(txHex)
+}
+
+func (b *EsploraBackend) TipHeight(ctx context.Context) (int64, error) {
+	body, err := b.do(ctx, http.MethodGet, "/blocks/tip/height", nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get tip height: %w", err)
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(body)), 10, 64)
+}
+
+func (b *EsploraBackend) getJSON(ctx context.Context, path string, result interface{}) error {
+	body, err := b.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+func (b *EsploraBackend) do(ctx context.Context, method string, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+
+	httpClient := b.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(respBody))}
+	}
+	return respBody, nil
+}
@@ -0,0 +1,142 @@
+package btcw
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestEsploraBackend(t *testing.T) {
+	chainParams := &chaincfg.TestNet3Params
+	address := "tb1qz40mujlemrru7t8t3yn3u5v3e9htmu5kektgme"
+	pkScript := addressScript(t, address, chainParams)
+
+	tx := newTestSpendTx(t, []*UTXO{newTestUTXO(0, 10000, pkScript)}, pkScript, 9000)
+	var buf bytes.Buffer
+	tx.Serialize(&buf)
+	txHex := hex.EncodeToString(buf.Bytes())
+	txid := tx.TxHash().String()
+
+	var broadcastBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/address/" + address + "/utxo":
+			fmt.Fprint(w, `[
+				{"txid":"bc26416ce0facd6733b26f5322b21f834ec9206eea9525ffd44e6c1102810fad","vout":1,"status":{"confirmed":true,"block_height":2866858,"block_hash":"0000000000000004","block_time":1720416330},"value":17891},
+				{"txid":"ae2db75b9d7bfb9bc3c39e238e1479df3021b206039f67528024ab123990feb4","vout":0,"status":{"confirmed":false},"value":15224}]`)
+		case "GET /api/address/" + address:
+			fmt.Fprint(w, `{"address":"`+address+`",
+				"chain_stats":{"funded_txo_count":3,"funded_txo_sum":50000,"spent_txo_count":1,"spent_txo_sum":32109,"tx_count":3},
+				"mempool_stats":{"funded_txo_count":1,"funded_txo_sum":15224,"spent_txo_count":0,"spent_txo_sum":0,"tx_count":1}}`)
+		case "GET /api/tx/" + txid + "/hex":
+			fmt.Fprint(w, txHex)
+		case "GET /api/tx/" + txid + "/status":
+			fmt.Fprint(w, `{"confirmed":true,"block_height":2866858,"block_hash":"0000000000000004","block_time":1720416330}`)
+		case "GET /api/tx/" + txid + "/merkle-proof":
+			fmt.Fprint(w, `{"block_height":2866858,"merkle":["aa","bb"],"pos":3}`)
+		case "GET /api/fee-estimates":
+			fmt.Fprint(w, `{"1":30.5,"2":25.1,"3":20,"6":12.2,"144":1.5,"504":1.1}`)
+		case "GET /api/blocks/tip/height":
+			fmt.Fprint(w, "2866877")
+		case "POST /api/tx":
+			body, _ := io.ReadAll(r.Body)
+			broadcastBody = string(body)
+			if broadcastBody != txHex {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, "sendrawtransaction RPC error: {\"code\":-22,\"message\":\"TX decode failed\"}")
+				return
+			}
+			fmt.Fprint(w, txid)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "not found")
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	backend := NewEsploraBackend(server.URL+"/api/", chainParams)
+
+	utxos, err := backend.ListUTXOs(ctx, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utxos) != 2 {
+		t.Fatalf("expected 2 utxos, got %d", len(utxos))
+	}
+	if utxos[0].TxIndex != 1 || utxos[0].Amount.Int64() != 17891 || utxos[0].BlockHeight != 2866858 {
+		t.Errorf("unexpected utxo %+v", utxos[0])
+	}
+	if utxos[1].BlockHeight != 0 {
+		t.Errorf("unconfirmed utxo should have block height 0")
+	}
+	for _, utxo := range utxos {
+		if !bytes.Equal(utxo.PKScript, pkScript) {
+			t.Errorf("PKScript should be filled")
+		}
+	}
+
+	balance, err := backend.GetBalance(ctx, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Balance != 17891 || balance.UnconfirmedBalance != 15224 || balance.FinalBalance != 33115 || balance.FinalNTx != 4 {
+		t.Errorf("unexpected balance %+v", balance)
+	}
+
+	tests := []struct {
+		targetBlocks int
+		feeRate      float64
+	}{
+		{0, 30.5}, {1, 30.5}, {2, 25.1}, {5, 20}, {6, 12.2}, {100, 12.2}, {1008, 1.1},
+	}
+	for _, test := range tests {
+		feeRate, err := backend.EstimateFee(ctx, test.targetBlocks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if feeRate != test.feeRate {
+			t.Errorf("target %d: got fee rate %v, expected %v", test.targetBlocks, feeRate, test.feeRate)
+		}
+	}
+
+	fetched, err := backend.GetTransaction(ctx, txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched.TxHash().String() != txid {
+		t.Errorf("unexpected transaction %s", fetched.TxHash())
+	}
+	status, err := backend.GetTxStatus(ctx, txid)
+	if err != nil || !status.Confirmed || status.BlockHeight != 2866858 {
+		t.Errorf("unexpected status %+v %v", status, err)
+	}
+	proof, err := backend.GetMerkleProof(ctx, txid)
+	if err != nil || proof.Pos != 3 || len(proof.Merkle) != 2 {
+		t.Errorf("unexpected merkle proof %+v %v", proof, err)
+	}
+
+	height, err := backend.TipHeight(ctx)
+	if err != nil || height != 2866877 {
+		t.Errorf("unexpected tip height %d %v", height, err)
+	}
+
+	broadcastTxid, err := backend.Broadcast(ctx, txHex)
+	if err != nil || broadcastTxid != txid {
+		t.Errorf("unexpected broadcast result %s %v", broadcastTxid, err)
+	}
+	if _, err := backend.Broadcast(ctx, "00"); err == nil {
+		t.Errorf("broadcast should fail on http status 400")
+	}
+
+	if _, err := backend.GetTxStatus(ctx, "unknown"); err == nil {
+		t.Errorf("unknown transaction should fail")
+	}
+}
@@ -0,0 +1,182 @@
+package btcw
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// HardenedKeyStart is the index of the first hardened child key (2^31).
+const HardenedKeyStart = hdkeychain.HardenedKeyStart
+
+// HDKey is a BIP32 extended key. A private HDKey derives child Wallets, a
+// public one (xpub/tpub) only derives child public keys for watch-only use.
+type HDKey struct {
+	key *hdkeychain.ExtendedKey
+	net *chaincfg.Params
+}
+
+// NewMasterKey creates a BIP32 master key from a 16 to 64 byte seed.
+func NewMasterKey(seed []byte, net *chaincfg.Params) (*HDKey, error) {
+	key, err := hdkeychain.NewMaster(seed, net)
+	if err != nil {
+		return nil, err
+	}
+	return &HDKey{key, net}, nil
+}
+
+// NewRandomMasterKey creates a master key from a fresh random seed.
+func NewRandomMasterKey(net *chaincfg.Params) (*HDKey, error) {
+	seed, err := hdkeychain.GenerateSeed(hdkeychain.RecommendedSeedLen)
+	if err != nil {
+		return nil, err
+	}
+	return NewMasterKey(seed, net)
+}
+
+// HDKeyFromString parses a serialized xprv, xpub, tprv or tpub key.
+// tprv/tpub keys are associated with testnet3; use SetNet for regtest or signet.
+func HDKeyFromString(key string) (*HDKey, error) {
+	extendedKey, err := hdkeychain.NewKeyFromString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	version := extendedKey.Version()
+	for _, net := range []*chaincfg.Params{&chaincfg.MainNetParams, &chaincfg.TestNet3Params} {
+		if bytes.Equal(version, net.HDPrivateKeyID[:]) || bytes.Equal(version, net.HDPublicKeyID[:]) {
+			return &HDKey{extendedKey, net}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown extended key version %x", version)
+}
+
+// Net returns the network the key is serialized for.
+func (k *HDKey) Net() *chaincfg.Params {
+	return k.net
+}
+
+// SetNet changes the network the key and its future children are serialized for.
+func (k *HDKey) SetNet(net *chaincfg.Params) {
+	k.key.SetNet(net)
+	k.net = net
+}
+
+// String returns the base58 serialization (xprv/xpub/tprv/tpub).
+func (k *HDKey) String() string {
+	return k.key.String()
+}
+
+func (k *HDKey) IsPrivate() bool {
+	return k.key.IsPrivate()
+}
+
+func (k *HDKey) Depth() uint8 {
+	return k.key.Depth()
+}
+
+func (k *HDKey) ChildIndex() uint32 {
+	return k.key.ChildIndex()
+}
+
+// Child derives the child key at index. Indexes at or above HardenedKeyStart
+// are hardened and can only be derived from a private key.
+func (k *HDKey) Child(index uint32) (*HDKey, error) {
+	child, err := k.key.Derive(index)
+	if err != nil {
+		return nil, err
+	}
+	return &HDKey{child, k.net}, nil
+}
+
+// Derive derives the key at path relative to k, e.g. "m/44'/0'/0'/0/1" or "0/1".
+// Hardened indexes are marked with ' or h.
+func (k *HDKey) Derive(path string) (*HDKey, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := k
+	for _, index := range indexes {
+		key, err = key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// Neuter returns the public (xpub/tpub) version of the key.
+func (k *HDKey) Neuter() (*HDKey, error) {
+	public, err := k.key.Neuter()
+	if err != nil {
+		return nil, err
+	}
+	return &HDKey{public, k.net}, nil
+}
+
+// PublicKey returns the compressed public key. It works for both private and
+// public extended keys, so it can be used with GetLegacyAddressFromPubKeyBytes
+// and GetSegwitAddressFromPubKeyBytes on a watch-only server.
+func (k *HDKey) PublicKey() ([]byte, error) {
+	pubKey, err := k.key.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	return pubKey.SerializeCompressed(), nil
+}
+
+// Wallet returns the Wallet for a private extended key.
+func (k *HDKey) Wallet() (*Wallet, error) {
+	if !k.key.IsPrivate() {
+		return nil, errors.New("cannot create a wallet from a public extended key")
+	}
+	privKey, err := k.key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+	return GetWalletFromPrivateKey(privKey), nil
+}
+
+// DeriveWallet is a shortcut for Derive followed by Wallet.
+func (k *HDKey) DeriveWallet(path string) (*Wallet, error) {
+	child, err := k.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+	return child.Wallet()
+}
+
+// ParseDerivationPath parses a BIP32 path such as "m/84'/0'/0'/0/5".
+// The leading "m" is optional and hardened indexes may use ' or h.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	path = strings.TrimSpace(path)
+	if path == "" || path == "m" || path == "m/" {
+		return nil, nil
+	}
+	path = strings.TrimPrefix(path, "m/")
+
+	var indexes []uint32
+	for _, element := range strings.Split(path, "/") {
+		hardened := strings.HasSuffix(element, "'") || strings.HasSuffix(element, "h") || strings.HasSuffix(element, "H")
+		if hardened {
+			element = element[:len(element)-1]
+		}
+
+		index, err := strconv.ParseUint(element, 10, 32)
+		if err != nil || index >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid derivation path element %q in %q", element, path)
+		}
+		if hardened {
+			index += HardenedKeyStart
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
@@ -0,0 +1,137 @@
+package btcw
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestHDKeyBIP32Vector1(t *testing.T) {
+	// https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki#test-vector-1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		wantPriv string
+		wantPub  string
+	}{
+		{
+			path:     "m",
+			wantPriv: "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi",
+			wantPub:  "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8",
+		},
+		{
+			path:     "m/0'/1",
+			wantPriv: "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs",
+			wantPub:  "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+		},
+		{
+			path:     "m/0h/1/2h/2/1000000000",
+			wantPriv: "xprvA41z7zogVVwxVSgdKUHDy1SKmdb533PjDz7J6N6mV6uS3ze1ai8FHa8kmHScGpWmj4WggLyQjgPie1rFSruoUihUZREPSL39UNdE3BBDu76",
+			wantPub:  "xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy",
+		},
+	}
+
+	for _, test := range tests {
+		child, err := master.Derive(test.path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.path, err)
+		}
+		if child.String() != test.wantPriv {
+			t.Errorf("%s: got private key %s", test.path, child.String())
+		}
+
+		public, err := child.Neuter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if public.String() != test.wantPub {
+			t.Errorf("%s: got public key %s", test.path, public.String())
+		}
+	}
+}
+
+func TestHDKeyLeadingZeros(t *testing.T) {
+	// https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki#test-vector-3
+	// 개인키 앞자리가 0 인 경우에도 표준대로 파생되어야 한다.
+	seed, _ := hex.DecodeString("4b381541583be4423346c643850da4b320e46a87ae3d2a4e6da11eba819cd4acba45d239319ac14f863b8d5ab5a0d0c64d2e8a1e7d1457df2e5a3c51c73235be")
+	master, _ := NewMasterKey(seed, &chaincfg.MainNetParams)
+	child, err := master.Derive("m/0'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.String() != "xprv9uPDJpEQgRQfDcW7BkF7eTya6RPxXeJCqCJGHuCJ4GiRVLzkTXBAJMu2qaMWPrS7AANYqdq6vcBcBUdJCVVFceUvJFjaPdGZ2y9WACViL4L" {
+		t.Errorf("got %s", child.String())
+	}
+}
+
+func TestHDKeyWatchOnly(t *testing.T) {
+	// xpub 만으로 파생한 공개키/주소는 개인키로 파생한 지갑의 주소와 같아야 한다.
+	net := &chaincfg.TestNet3Params
+	master, err := NewRandomMasterKey(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, _ := master.Derive("m/84'/1'/0'")
+	accountPub, _ := account.Neuter()
+
+	xpub, err := HDKeyFromString(accountPub.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xpub.IsPrivate() || xpub.Net() != net {
+		t.Errorf("tpub should parse as a public testnet key")
+	}
+
+	wallet, err := account.DeriveWallet("0/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	watchOnly, err := xpub.Derive("0/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, _ := watchOnly.PublicKey()
+
+	if !bytes.Equal(pubKey, wallet.PublicKey) {
+		t.Errorf("watch-only public key should match the wallet public key")
+	}
+	if address, _ := GetSegwitAddressFromPubKeyBytes(pubKey, net); address != wallet.GetSegwitAddress(net) {
+		t.Errorf("watch-only address should match the wallet address")
+	}
+
+	if _, err := xpub.Derive("0'/1"); err == nil {
+		t.Errorf("hardened derivation from a public key should fail")
+	}
+	if _, err := watchOnly.Wallet(); err == nil {
+		t.Errorf("wallet from a public key should fail")
+	}
+}
+
+func TestParseDerivationPath(t *testing.T) {
+	indexes, err := ParseDerivationPath("m/44'/1h/0'/1/20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint32{HardenedKeyStart + 44, HardenedKeyStart + 1, HardenedKeyStart, 1, 20}
+	if len(indexes) != len(expected) {
+		t.Fatalf("got %v", indexes)
+	}
+	for i := range expected {
+		if indexes[i] != expected[i] {
+			t.Errorf("index %d: got %d, expected %d", i, indexes[i], expected[i])
+		}
+	}
+
+	for _, path := range []string{"m/a", "m//1", "m/2147483648", "m/1/-1"} {
+		if _, err := ParseDerivationPath(path); err == nil {
+			t.Errorf("expected error for path %q", path)
+		}
+	}
+}
@@ -0,0 +1,520 @@
+package btcw
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"golang.org/x/crypto/scrypt"
+)
+
+const keystoreVersion = 1
+
+// keystoreCheck is encrypted with the passphrase key so Unlock can verify the
+// passphrase even when the keystore holds no keys.
+const keystoreCheck = "btcw keystore"
+
+var (
+	ErrKeystoreLocked  = errors.New("keystore is locked")
+	ErrWrongPassphrase = errors.New("wrong keystore passphrase")
+)
+
+// ScryptParams are the cost parameters of the keystore KDF.
+type ScryptParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+var (
+	// StandardScryptParams uses 256MB of memory and takes about a second.
+	StandardScryptParams = ScryptParams{N: 1 << 18, R: 8, P: 1}
+	// LightScryptParams uses 4MB of memory, for tests and small devices.
+	LightScryptParams = ScryptParams{N: 1 << 12, R: 8, P: 6}
+)
+
+type Wallets struct {
+	Wallets map[string]*Wallet
+}
+
+func NewWallets() *Wallets {
+	return &Wallets{make(map[string]*Wallet)}
+}
+
+func (ws *Wallets) AddWallet(address string, wallet *Wallet) {
+	ws.Wallets[address] = wallet
+}
+
+func (ws *Wallets) GetWallet(address string) (*Wallet, bool) {
+	wallet, ok := ws.Wallets[address]
+	return wallet, ok
+}
+
+// GetAddresses returns the addresses of the wallets in sorted order.
+func (ws *Wallets) GetAddresses() []string {
+	var addresses []string
+	for address := range ws.Wallets {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+/*
+keystore file example
+
+	{
+		"version": 1,
+		"kdf": {"name": "scrypt", "n": 262144, "r": 8, "p": 1, "salt": "..."},
+		"check": {"nonce": "...", "ciphertext": "..."},
+		"keys": [
+			{
+				"address": "tb1q...",
+				"network": "testnet3",
+				"compressed": true,
+				"nonce": "...",
+				"ciphertext": "..."
+			}
+		]
+	}
+*/
+type keystoreFile struct {
+	Version int                `json:"version"`
+	KDF     keystoreKDF        `json:"kdf"`
+	Check   keystoreCipherText `json:"check"`
+	Keys    []keystoreKey      `json:"keys"`
+}
+
+type keystoreKDF struct {
+	Name string `json:"name"`
+	ScryptParams
+	Salt string `json:"salt"`
+}
+
+type keystoreCipherText struct {
+	Nonce      string `json:"nonce"`
+	CipherText string `json:"ciphertext"`
+}
+
+type keystoreKey struct {
+	Address    string `json:"address"`
+	Network    string `json:"network,omitempty"`
+	Compressed bool   `json:"compressed"`
+	keystoreCipherText
+}
+
+// Keystore is an on-disk file of wallets whose private keys are encrypted
+// with AES-256-GCM under a scrypt derived passphrase key. Addresses are
+// stored in plain text so they can be listed while the keystore is locked.
+type Keystore struct {
+	path string
+	mu   sync.Mutex
+	file keystoreFile
+	key  []byte // nil 이면 잠긴 상태
+}
+
+// NewKeystore creates a new, empty and unlocked keystore file at path.
+func NewKeystore(path string, passphrase string, params ScryptParams) (*Keystore, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("keystore %s already exists", path)
+	}
+
+	ks := &Keystore{path: path, file: keystoreFile{Version: keystoreVersion}}
+	if err := ks.setPassphrase(passphrase, params); err != nil {
+		return nil, err
+	}
+	if err := ks.save(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// OpenKeystore reads a keystore file. The returned keystore is locked.
+func OpenKeystore(path string) (*Keystore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file keystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid keystore %s: %w", path, err)
+	}
+	if file.Version != keystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d", file.Version)
+	}
+	if file.KDF.Name != "scrypt" {
+		return nil, fmt.Errorf("unsupported keystore kdf %q", file.KDF.Name)
+	}
+	return &Keystore{path: path, file: file}, nil
+}
+
+// LoadWallets opens and unlocks the keystore at path and returns its wallets.
+func LoadWallets(path string, passphrase string) (*Wallets, error) {
+	ks, err := OpenKeystore(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.Unlock(passphrase); err != nil {
+		return nil, err
+	}
+	defer ks.Lock()
+	return ks.Wallets()
+}
+
+func (ks *Keystore) Path() string {
+	return ks.path
+}
+
+// Addresses lists the stored addresses. It does not need the keystore unlocked.
+func (ks *Keystore) Addresses() []string {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	var addresses []string
+	for _, key := range ks.file.Keys {
+		addresses = append(addresses, key.Address)
+	}
+	return addresses
+}
+
+func (ks *Keystore) IsLocked() bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.key == nil
+}
+
+// Unlock derives the passphrase key and keeps it in memory until Lock.
+func (ks *Keystore) Unlock(passphrase string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, err := ks.file.KDF.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+	check, err := decryptKeystoreCipherText(key, ks.file.Check, nil)
+	if err != nil || string(check) != keystoreCheck {
+		return ErrWrongPassphrase
+	}
+	ks.key = key
+	return nil
+}
+
+// Lock forgets the passphrase key.
+func (ks *Keystore) Lock() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	zeroBytes(ks.key)
+	ks.key = nil
+}
+
+// AddWallet encrypts wallet's private key under address and saves the keystore.
+func (ks *Keystore) AddWallet(address string, wallet *Wallet) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.key == nil {
+		return ErrKeystoreLocked
+	}
+	for _, key := range ks.file.Keys {
+		if key.Address == address {
+			return fmt.Errorf("address %s is already in the keystore", address)
+		}
+	}
+	// address 는 associated data 로도 쓰이므로 다른 키의 주소와 묶어 저장하면 안 된다.
+	if !isWalletAddress(wallet, address) {
+		return fmt.Errorf("address %s is not an address of the wallet", address)
+	}
+
+	privateKey := wallet.PrivateKeyToBytes()
+	defer zeroBytes(privateKey)
+	cipherText, err := encryptKeystoreCipherText(ks.key, privateKey, []byte(address))
+	if err != nil {
+		return err
+	}
+
+	entry := keystoreKey{Address: address, Compressed: wallet.Compressed, keystoreCipherText: cipherText}
+	if wallet.Net != nil {
+		entry.Network = wallet.Net.Name
+	}
+	ks.file.Keys = append(ks.file.Keys, entry)
+	if err := ks.save(); err != nil {
+		ks.file.Keys = ks.file.Keys[:len(ks.file.Keys)-1]
+		return err
+	}
+	return nil
+}
+
+// isWalletAddress reports whether address is the P2PKH, P2WPKH, P2SH-P2WPKH
+// or P2TR address of wallet's key on the network of the wallet, or on any
+// known network if the wallet has none.
+func isWalletAddress(wallet *Wallet, address string) bool {
+	nets := []*chaincfg.Params{wallet.Net}
+	if wallet.Net == nil {
+		nets = nets[:0]
+		for _, net := range networks {
+			nets = append(nets, net)
+		}
+	}
+	for _, net := range nets {
+		for _, walletAddress := range []string{
+			wallet.GetLegacyAddress2(net),
+			wallet.GetSegwitAddress(net),
+			wallet.GetNestedSegwitAddress(net),
+			wallet.GetTaprootAddress(net),
+		} {
+			if walletAddress == address {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RemoveWallet deletes address from the keystore and saves it.
+func (ks *Keystore) RemoveWallet(address string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.key == nil {
+		return ErrKeystoreLocked
+	}
+	keys := ks.file.Keys
+	for i, key := range keys {
+		if key.Address == address {
+			ks.file.Keys = append(append([]keystoreKey{}, keys[:i]...), keys[i+1:]...)
+			if err := ks.save(); err != nil {
+				ks.file.Keys = keys
+				return err
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("address %s is not in the keystore", address)
+}
+
+// Wallets decrypts every key of an unlocked keystore.
+func (ks *Keystore) Wallets() (*Wallets, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.key == nil {
+		return nil, ErrKeystoreLocked
+	}
+
+	wallets := NewWallets()
+	for _, key := range ks.file.Keys {
+		wallet, err := key.decrypt(ks.key)
+		if err != nil {
+			return nil, err
+		}
+		wallets.AddWallet(key.Address, wallet)
+	}
+	return wallets, nil
+}
+
+// ChangePassphrase re-encrypts every key with a new salt and passphrase.
+// The keystore is left unlocked with the new passphrase.
+func (ks *Keystore) ChangePassphrase(oldPassphrase string, newPassphrase string) error {
+	if err := ks.Unlock(oldPassphrase); err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	var privateKeys [][]byte
+	defer func() {
+		for _, privateKey := range privateKeys {
+			zeroBytes(privateKey)
+		}
+	}()
+	for _, key := range ks.file.Keys {
+		privateKey, err := decryptKeystoreCipherText(ks.key, key.keystoreCipherText, []byte(key.Address))
+		if err != nil {
+			return err
+		}
+		privateKeys = append(privateKeys, privateKey)
+	}
+
+	oldFile, oldKey := ks.file, ks.key
+	ks.file.Keys = append([]keystoreKey{}, oldFile.Keys...)
+	if err := ks.setPassphrase(newPassphrase, oldFile.KDF.ScryptParams); err != nil {
+		ks.file, ks.key = oldFile, oldKey
+		return err
+	}
+	for i := range ks.file.Keys {
+		cipherText, err := encryptKeystoreCipherText(ks.key, privateKeys[i], []byte(ks.file.Keys[i].Address))
+		if err != nil {
+			ks.file, ks.key = oldFile, oldKey
+			return err
+		}
+		ks.file.Keys[i].keystoreCipherText = cipherText
+	}
+
+	if err := ks.save(); err != nil {
+		ks.file, ks.key = oldFile, oldKey
+		return err
+	}
+	zeroBytes(oldKey)
+	return nil
+}
+
+// setPassphrase picks a new salt, derives the key and re-encrypts the check value.
+func (ks *Keystore) setPassphrase(passphrase string, params ScryptParams) error {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	kdf := keystoreKDF{Name: "scrypt", ScryptParams: params, Salt: hex.EncodeToString(salt)}
+	key, err := kdf.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+	check, err := encryptKeystoreCipherText(key, []byte(keystoreCheck), nil)
+	if err != nil {
+		return err
+	}
+
+	ks.file.KDF = kdf
+	ks.file.Check = check
+	ks.key = key
+	return nil
+}
+
+func (ks *Keystore) save() error {
+	data, err := json.MarshalIndent(ks.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(ks.path, data, 0600)
+}
+
+func (kdf keystoreKDF) deriveKey(passphrase string) ([]byte, error) {
+	salt, err := hex.DecodeString(kdf.Salt)
+	if err != nil {
+		return nil, err
+	}
+	return scrypt.Key([]byte(passphrase), salt, kdf.N, kdf.R, kdf.P, 32)
+}
+
+func (key keystoreKey) decrypt(passphraseKey []byte) (*Wallet, error) {
+	privateKeyBytes, err := decryptKeystoreCipherText(passphraseKey, key.keystoreCipherText, []byte(key.Address))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key of %s: %w", key.Address, err)
+	}
+	defer zeroBytes(privateKeyBytes)
+
+	privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
+	wallet := GetWalletFromPrivateKey(privateKey)
+	if !key.Compressed {
+		wallet.Compressed = false
+		wallet.PublicKey = wallet.SerializePubKeyUncompressed()
+	}
+	if key.Network != "" {
+		wallet.Net, err = netParamsByName(key.Network)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return wallet, nil
+}
+
+// encryptKeystoreCipherText encrypts plainText with AES-256-GCM. additionalData
+// (the address) binds a key to its entry so entries cannot be swapped.
+func encryptKeystoreCipherText(key []byte, plainText []byte, additionalData []byte) (keystoreCipherText, error) {
+	aead, err := newKeystoreAEAD(key)
+	if err != nil {
+		return keystoreCipherText{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return keystoreCipherText{}, err
+	}
+	cipherText := aead.Seal(nil, nonce, plainText, additionalData)
+	return keystoreCipherText{hex.EncodeToString(nonce), hex.EncodeToString(cipherText)}, nil
+}
+
+func decryptKeystoreCipherText(key []byte, cipherText keystoreCipherText, additionalData []byte) ([]byte, error) {
+	aead, err := newKeystoreAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(cipherText.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid keystore nonce")
+	}
+	data, err := hex.DecodeString(cipherText.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, nonce, data, additionalData)
+}
+
+func newKeystoreAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and
+// renames it over path, so a crash leaves either the old or the new file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// rename 자체가 디스크에 기록되도록 디렉터리도 sync 한다.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+func netParamsByName(name string) (*chaincfg.Params, error) {
+	return ParseNetwork(name)
+}
+
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,160 @@
+package btcw
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestKeystoreSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.json")
+	ks, err := NewKeystore(path, "correct horse", LightScryptParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	net := &chaincfg.TestNet3Params
+	segwitWallet, _ := CreateNewWallet()
+	segwitAddress := segwitWallet.GetSegwitAddress(net)
+	legacyWallet, _ := WalletFromWIF("5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ")
+	legacyAddress := legacyWallet.GetLegacyAddress(BITCOIN_MAINNET_VERSION)
+
+	if err := ks.AddWallet(segwitAddress, segwitWallet); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.AddWallet(legacyAddress, legacyWallet); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.AddWallet(legacyAddress, legacyWallet); err == nil {
+		t.Errorf("adding the same address twice should fail")
+	}
+	// 다른 키의 주소나 WIF 와 다른 network 의 주소는 저장하지 않는다.
+	if err := ks.AddWallet(segwitWallet.GetTaprootAddress(net), legacyWallet); err == nil {
+		t.Errorf("adding an address of another key should fail")
+	}
+	if err := ks.AddWallet(legacyWallet.GetSegwitAddress(net), legacyWallet); err == nil {
+		t.Errorf("adding an address for another network than the WIF should fail")
+	}
+
+	// 파일에는 개인키가 평문으로 저장되면 안 된다.
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), hex.EncodeToString(segwitWallet.PrivateKeyToBytes())) {
+		t.Errorf("keystore file should not contain the plain private key")
+	}
+
+	wallets, err := LoadWallets(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wallets.GetAddresses()) != 2 {
+		t.Fatalf("expected 2 wallets, got %v", wallets.GetAddresses())
+	}
+	loadedSegwit, _ := wallets.GetWallet(segwitAddress)
+	if loadedSegwit.GetSegwitAddress(net) != segwitAddress {
+		t.Errorf("loaded wallet should have the same address")
+	}
+	loadedLegacy, _ := wallets.GetWallet(legacyAddress)
+	if loadedLegacy.Compressed || loadedLegacy.Net != &chaincfg.MainNetParams {
+		t.Errorf("compression flag and network should be kept")
+	}
+	if loadedLegacy.GetLegacyAddress(BITCOIN_MAINNET_VERSION) != legacyAddress {
+		t.Errorf("loaded uncompressed wallet should have the same address")
+	}
+
+	if _, err := LoadWallets(path, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+}
+
+func TestKeystoreLockUnlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.json")
+	ks, err := NewKeystore(path, "pass", LightScryptParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wallet, _ := CreateNewWallet()
+	address := wallet.GetSegwitAddress(&chaincfg.MainNetParams)
+	ks.AddWallet(address, wallet)
+	ks.Lock()
+
+	if !ks.IsLocked() {
+		t.Errorf("keystore should be locked")
+	}
+	if _, err := ks.Wallets(); !errors.Is(err, ErrKeystoreLocked) {
+		t.Errorf("expected ErrKeystoreLocked, got %v", err)
+	}
+	if err := ks.AddWallet("x", wallet); !errors.Is(err, ErrKeystoreLocked) {
+		t.Errorf("expected ErrKeystoreLocked, got %v", err)
+	}
+
+	// 잠긴 상태에서도 주소 목록은 볼 수 있다.
+	opened, err := OpenKeystore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addresses := opened.Addresses(); len(addresses) != 1 || addresses[0] != address {
+		t.Errorf("unexpected addresses %v", addresses)
+	}
+
+	if err := opened.Unlock("nope"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := opened.Unlock("pass"); err != nil {
+		t.Fatal(err)
+	}
+	if err := opened.RemoveWallet(address); err != nil {
+		t.Fatal(err)
+	}
+	reopened, _ := OpenKeystore(path)
+	if len(reopened.Addresses()) != 0 {
+		t.Errorf("removed wallet should not be in the file")
+	}
+}
+
+func TestKeystoreChangePassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.json")
+	ks, _ := NewKeystore(path, "old", LightScryptParams)
+	wallet, _ := CreateNewWallet()
+	address := wallet.GetSegwitAddress(&chaincfg.MainNetParams)
+	ks.AddWallet(address, wallet)
+
+	if err := ks.ChangePassphrase("bad", "new"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := ks.ChangePassphrase("old", "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadWallets(path, "old"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("old passphrase should not work after the change")
+	}
+	wallets, err := LoadWallets(path, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, ok := wallets.GetWallet(address)
+	if !ok || hex.EncodeToString(loaded.PrivateKeyToBytes()) != hex.EncodeToString(wallet.PrivateKeyToBytes()) {
+		t.Errorf("private key should survive a passphrase change")
+	}
+
+	// 임시 파일이 남아 있으면 안 된다.
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the keystore file, got %d entries", len(entries))
+	}
+}
+
+func TestNewKeystoreExists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.json")
+	if _, err := NewKeystore(path, "a", LightScryptParams); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewKeystore(path, "b", LightScryptParams); err == nil {
+		t.Errorf("creating a keystore over an existing file should fail")
+	}
+}
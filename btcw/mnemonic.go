@@ -0,0 +1,146 @@
+package btcw
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki
+
+var ErrMnemonicChecksum = errors.New("mnemonic checksum mismatch")
+
+var bip39EnglishWordIndex = func() map[string]int {
+	index := make(map[string]int, len(bip39EnglishWordList))
+	for i, word := range bip39EnglishWordList {
+		index[word] = i
+	}
+	return index
+}()
+
+// NewEntropy returns random entropy for a mnemonic. bitSize must be a multiple
+// of 32 between 128 (12 words) and 256 (24 words).
+func NewEntropy(bitSize int) ([]byte, error) {
+	if err := validateEntropyBitSize(bitSize); err != nil {
+		return nil, err
+	}
+	entropy := make([]byte, bitSize/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, err
+	}
+	return entropy, nil
+}
+
+// NewRandomMnemonic generates a new 12, 15, 18, 21 or 24 word mnemonic.
+func NewRandomMnemonic(wordCount int) (string, error) {
+	entropy, err := NewEntropy(wordCount * 11 * 32 / 33)
+	if err != nil {
+		return "", fmt.Errorf("invalid mnemonic word count %d", wordCount)
+	}
+	return NewMnemonic(entropy)
+}
+
+// NewMnemonic encodes entropy as an English mnemonic sentence.
+func NewMnemonic(entropy []byte) (string, error) {
+	entropyBits := len(entropy) * 8
+	if err := validateEntropyBitSize(entropyBits); err != nil {
+		return "", err
+	}
+
+	// entropy 뒤에 sha256(entropy) 의 앞 ENT/32 bit 를 checksum 으로 붙이고 11 bit 씩 단어로 바꾼다.
+	checksumBits := entropyBits / 32
+	hash := sha256.Sum256(entropy)
+	data := append(append([]byte{}, entropy...), hash[0])
+
+	wordCount := (entropyBits + checksumBits) / 11
+	words := make([]string, wordCount)
+	for i := 0; i < wordCount; i++ {
+		index := 0
+		for bit := i * 11; bit < (i+1)*11; bit++ {
+			index = index<<1 | int(data[bit/8]>>(7-uint(bit%8))&1)
+		}
+		words[i] = bip39EnglishWordList[index]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy decodes a mnemonic back to its entropy. It fails on
+// unknown words and on checksum mismatch, so most typos are rejected.
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(norm.NFKD.String(mnemonic))
+	wordCount := len(words)
+	if wordCount%3 != 0 || wordCount < 12 || wordCount > 24 {
+		return nil, fmt.Errorf("invalid mnemonic word count %d", wordCount)
+	}
+
+	totalBits := wordCount * 11
+	checksumBits := totalBits / 33
+	entropyBits := totalBits - checksumBits
+
+	data := make([]byte, (totalBits+7)/8)
+	for i, word := range words {
+		index, ok := bip39EnglishWordIndex[word]
+		if !ok {
+			return nil, fmt.Errorf("invalid mnemonic word %q at position %d", word, i+1)
+		}
+		for b := 0; b < 11; b++ {
+			if index&(1<<uint(10-b)) != 0 {
+				bit := i*11 + b
+				data[bit/8] |= 1 << (7 - uint(bit%8))
+			}
+		}
+	}
+
+	entropy := data[:entropyBits/8]
+	hash := sha256.Sum256(entropy)
+	mask := byte(0xff << uint(8-checksumBits))
+	if hash[0]&mask != data[entropyBits/8]&mask {
+		return nil, ErrMnemonicChecksum
+	}
+	return entropy, nil
+}
+
+// ValidateMnemonic returns nil if mnemonic is a valid English BIP39 mnemonic.
+func ValidateMnemonic(mnemonic string) error {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err
+}
+
+// MnemonicToSeed derives the 64 byte BIP39 seed with PBKDF2-HMAC-SHA512.
+// It does not validate the mnemonic, use NewSeedFromMnemonic for that.
+func MnemonicToSeed(mnemonic string, passphrase string) []byte {
+	mnemonic = strings.Join(strings.Fields(norm.NFKD.String(mnemonic)), " ")
+	salt := "mnemonic" + norm.NFKD.String(passphrase)
+	return pbkdf2.Key([]byte(mnemonic), []byte(salt), 2048, 64, sha512.New)
+}
+
+// NewSeedFromMnemonic validates mnemonic and derives its seed.
+func NewSeedFromMnemonic(mnemonic string, passphrase string) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	return MnemonicToSeed(mnemonic, passphrase), nil
+}
+
+// NewMasterKeyFromMnemonic restores the BIP32 master key of a mnemonic wallet.
+func NewMasterKeyFromMnemonic(mnemonic string, passphrase string, net *chaincfg.Params) (*HDKey, error) {
+	seed, err := NewSeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewMasterKey(seed, net)
+}
+
+func validateEntropyBitSize(bitSize int) error {
+	if bitSize%32 != 0 || bitSize < 128 || bitSize > 256 {
+		return fmt.Errorf("invalid entropy size %d bits", bitSize)
+	}
+	return nil
+}
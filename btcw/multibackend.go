@@ -0,0 +1,545 @@
+package btcw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ErrQuorumDisagreement is matched by a QuorumError.
+var ErrQuorumDisagreement = errors.New("chain backends disagree")
+
+// NamedBackend is a ChainBackend with a name used in errors and status.
+type NamedBackend struct {
+	Name string
+	ChainBackend
+}
+
+// BackendError is the error of one backend.
+type BackendError struct {
+	Name string
+	Err  error
+}
+
+func (e *BackendError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// MultiBackendError is returned by MultiBackend when every backend failed or
+// was skipped by its circuit breaker. It matches ErrBackendUnavailable.
+type MultiBackendError struct {
+	Method string
+	Errors []*BackendError
+}
+
+func (e *MultiBackendError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("%s: %v", e.Method, ErrBackendUnavailable)
+	}
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%s: %v: %s", e.Method, ErrBackendUnavailable, strings.Join(messages, "; "))
+}
+
+func (e *MultiBackendError) Is(target error) bool {
+	return target == ErrBackendUnavailable
+}
+
+// QuorumError is returned in quorum mode when the backends return different
+// results. Results has the summary of each backend's result and Errors the
+// backends that failed. It matches ErrQuorumDisagreement.
+type QuorumError struct {
+	Method  string
+	Results map[string]string
+	Errors  []*BackendError
+}
+
+func (e *QuorumError) Error() string {
+	names := make([]string, 0, len(e.Results))
+	for name := range e.Results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	results := make([]string, len(names))
+	for i, name := range names {
+		results[i] = fmt.Sprintf("%s=%s", name, e.Results[name])
+	}
+	for _, err := range e.Errors {
+		results = append(results, err.Error())
+	}
+	return fmt.Sprintf("%s: %v: %s", e.Method, ErrQuorumDisagreement, strings.Join(results, "; "))
+}
+
+func (e *QuorumError) Is(target error) bool {
+	return target == ErrQuorumDisagreement
+}
+
+// BackendStatus is the health of one backend of a MultiBackend.
+type BackendStatus struct {
+	Name                string
+	Healthy             bool
+	ConsecutiveFailures int
+	// OpenUntil is when the circuit breaker lets requests through again.
+	OpenUntil time.Time
+	LastError error
+	TipHeight int64
+}
+
+type backendState struct {
+	NamedBackend
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	openUntil           time.Time
+	lastErr             error
+	tipHeight           int64
+}
+
+// MultiBackend is a ChainBackend over several backends. Requests go to the
+// first available backend in priority order and fail over to the next one on
+// errors. A backend that fails FailureThreshold times in a row is skipped for
+// OpenDuration (circuit breaker), then gets one request to check if it is back.
+//
+// Errors where the backend answered, e.g. a broadcast rejected by the node or
+// a 4xx status, are returned without failover because the other backends
+// would give the same answer.
+//
+// If Quorum is more than 1, ListUTXOs and Broadcast are sent to all available
+// backends and at least Quorum of them must succeed with the same result,
+// otherwise a QuorumError is returned.
+type MultiBackend struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+	// AttemptTimeout limits each request to a single backend, 0 means no limit.
+	AttemptTimeout time.Duration
+	// MaxTipLag is how many blocks a backend may be behind the others before
+	// CheckHealth marks it as unhealthy.
+	MaxTipLag int64
+	Quorum    int
+
+	backends []*backendState
+	now      func() time.Time
+}
+
+// NewMultiBackend returns a MultiBackend over backends in priority order.
+func NewMultiBackend(backends ...NamedBackend) *MultiBackend {
+	m := &MultiBackend{
+		FailureThreshold: 3,
+		OpenDuration:     time.Minute,
+		AttemptTimeout:   30 * time.Second,
+		MaxTipLag:        3,
+		now:              time.Now,
+	}
+	for _, backend := range backends {
+		m.backends = append(m.backends, &backendState{NamedBackend: backend})
+	}
+	return m
+}
+
+// Status returns the health of each backend in priority order.
+func (m *MultiBackend) Status() []BackendStatus {
+	now := m.now()
+	statuses := make([]BackendStatus, len(m.backends))
+	for i, state := range m.backends {
+		state.mu.Lock()
+		statuses[i] = BackendStatus{
+			Name:                state.Name,
+			Healthy:             !now.Before(state.openUntil),
+			ConsecutiveFailures: state.consecutiveFailures,
+			OpenUntil:           state.openUntil,
+			LastError:           state.lastErr,
+			TipHeight:           state.tipHeight,
+		}
+		state.mu.Unlock()
+	}
+	return statuses
+}
+
+// CheckHealth asks every backend for its tip height. Backends that fail or
+// are more than MaxTipLag blocks behind have their circuit opened, the others
+// are closed.
+func (m *MultiBackend) CheckHealth(ctx context.Context) []BackendStatus {
+	heights := make([]int64, len(m.backends))
+	errs := make([]error, len(m.backends))
+	var wg sync.WaitGroup
+	for i, state := range m.backends {
+		wg.Add(1)
+		go func(i int, state *backendState) {
+			defer wg.Done()
+			errs[i] = m.attempt(ctx, func(ctx context.Context) error {
+				var err error
+				heights[i], err = state.TipHeight(ctx)
+				return err
+			})
+		}(i, state)
+	}
+	wg.Wait()
+
+	var maxHeight int64
+	for i, err := range errs {
+		if err == nil && heights[i] > maxHeight {
+			maxHeight = heights[i]
+		}
+	}
+	for i, state := range m.backends {
+		err := errs[i]
+		if err == nil && maxHeight-heights[i] > m.MaxTipLag {
+			err = fmt.Errorf("tip height %d is %d blocks behind", heights[i], maxHeight-heights[i])
+		}
+
+		state.mu.Lock()
+		if err == nil {
+			state.tipHeight = heights[i]
+			state.consecutiveFailures = 0
+			state.openUntil = time.Time{}
+		} else {
+			state.consecutiveFailures++
+			state.openUntil = m.now().Add(m.OpenDuration)
+		}
+		state.lastErr = err
+		state.mu.Unlock()
+	}
+	return m.Status()
+}
+
+// StartHealthChecks runs CheckHealth every interval until stop is called.
+func (m *MultiBackend) StartHealthChecks(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.CheckHealth(ctx)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// available reports whether the circuit of state lets a request through. An
+// open circuit lets one request through after OpenDuration.
+func (m *MultiBackend) available(state *backendState) bool {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	now := m.now()
+	if now.Before(state.openUntil) {
+		return false
+	}
+	if state.consecutiveFailures >= m.FailureThreshold {
+		// half open, block the others until this request is done
+		state.openUntil = now.Add(m.OpenDuration)
+	}
+	return true
+}
+
+func (m *MultiBackend) record(state *backendState, err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if err == nil {
+		state.consecutiveFailures = 0
+		state.openUntil = time.Time{}
+		return
+	}
+	state.lastErr = err
+	state.consecutiveFailures++
+	if state.consecutiveFailures >= m.FailureThreshold {
+		state.openUntil = m.now().Add(m.OpenDuration)
+	}
+}
+
+func (m *MultiBackend) attempt(ctx context.Context, call func(ctx context.Context) error) error {
+	if m.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.AttemptTimeout)
+		defer cancel()
+	}
+	return call(ctx)
+}
+
+// isRejection reports whether err is an answer of a working backend rather
+// than a failure of the backend.
+func isRejection(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode
+		return code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+	}
+	var rpcErr *btcjson.RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code != btcjson.ErrRPCInWarmup && rpcErr.Code != btcjson.ErrRPCClientInInitialDownload
+	}
+	var electrumErr *ElectrumError
+	return errors.As(err, &electrumErr)
+}
+
+// failover calls call on the available backends in priority order until one
+// succeeds.
+func (m *MultiBackend) failover(ctx context.Context, method string, call func(ctx context.Context, backend ChainBackend) error) error {
+	multiErr := &MultiBackendError{Method: method}
+	for _, state := range m.backends {
+		if !m.available(state) {
+			multiErr.Errors = append(multiErr.Errors, &BackendError{Name: state.Name, Err: errors.New("circuit open")})
+			continue
+		}
+
+		err := m.attempt(ctx, func(ctx context.Context) error {
+			return call(ctx, state.ChainBackend)
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil || isRejection(err) {
+			m.record(state, nil)
+			return err
+		}
+		m.record(state, err)
+		multiErr.Errors = append(multiErr.Errors, &BackendError{Name: state.Name, Err: err})
+	}
+	return multiErr
+}
+
+type quorumResult struct {
+	name    string
+	summary string
+	value   interface{}
+	err     error
+}
+
+// quorum calls call on all available backends at once. At least Quorum of
+// them must succeed and all results must have the same summary.
+func (m *MultiBackend) quorum(ctx context.Context, method string, call func(ctx context.Context, backend ChainBackend) (interface{}, string, error)) (interface{}, error) {
+	var states []*backendState
+	for _, state := range m.backends {
+		if m.available(state) {
+			states = append(states, state)
+		}
+	}
+
+	results := make([]quorumResult, len(states))
+	var wg sync.WaitGroup
+	for i, state := range states {
+		wg.Add(1)
+		go func(i int, state *backendState) {
+			defer wg.Done()
+			result := quorumResult{name: state.Name}
+			result.err = m.attempt(ctx, func(ctx context.Context) error {
+				var err error
+				result.value, result.summary, err = call(ctx, state.ChainBackend)
+				return err
+			})
+			results[i] = result
+		}(i, state)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var succeeded []quorumResult
+	var errs []*BackendError
+	for i, result := range results {
+		if result.err == nil || isRejection(result.err) {
+			m.record(states[i], nil)
+		} else {
+			m.record(states[i], result.err)
+		}
+		if result.err != nil {
+			errs = append(errs, &BackendError{Name: result.name, Err: result.err})
+			continue
+		}
+		succeeded = append(succeeded, result)
+	}
+
+	agree := true
+	for _, result := range succeeded {
+		if result.summary != succeeded[0].summary {
+			agree = false
+		}
+	}
+	if !agree || (len(succeeded) > 0 && len(errs) > 0 && allRejections(errs)) {
+		quorumErr := &QuorumError{Method: method, Results: make(map[string]string), Errors: errs}
+		for _, result := range succeeded {
+			quorumErr.Results[result.name] = result.summary
+		}
+		return nil, quorumErr
+	}
+	if len(succeeded) < m.Quorum {
+		if len(succeeded) == 0 && len(errs) > 0 && allRejections(errs) {
+			return nil, errs[0].Err
+		}
+		return nil, &MultiBackendError{Method: fmt.Sprintf("%s quorum %d/%d", method, len(succeeded), m.Quorum), Errors: errs}
+	}
+	return succeeded[0].value, nil
+}
+
+// alreadyKnownMessages are parts of the errors backends return for a
+// transaction that is already in their mempool or in the chain.
+var alreadyKnownMessages = []string{
+	"txn-already-in-mempool",
+	"txn-already-known",
+	"already in block chain",
+	"already in mempool",
+	"already known",
+	"already exists",
+}
+
+// isAlreadyKnown reports whether err is a backend's answer to broadcasting a
+// transaction it already has, e.g. received by P2P from another backend.
+func isAlreadyKnown(err error) bool {
+	var rpcErr *btcjson.RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == btcjson.ErrRPCVerifyAlreadyInChain {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, known := range alreadyKnownMessages {
+		if strings.Contains(message, known) {
+			return true
+		}
+	}
+	return false
+}
+
+func allRejections(errs []*BackendError) bool {
+	for _, err := range errs {
+		if !isRejection(err.Err) {
+			return false
+		}
+	}
+	return true
+}
+
+// utxoSetSummary returns the sorted outpoints and amounts of utxos.
+func utxoSetSummary(utxos []*UTXO) string {
+	outpoints := make([]string, len(utxos))
+	for i, utxo := range utxos {
+		outpoints[i] = fmt.Sprintf("%s:%d=%s", utxo.Hash, utxo.TxIndex, utxo.Amount)
+	}
+	sort.Strings(outpoints)
+	return "[" + strings.Join(outpoints, ",") + "]"
+}
+
+func (m *MultiBackend) ListUTXOs(ctx context.Context, address string) ([]*UTXO, error) {
+	if m.Quorum > 1 {
+		result, err := m.quorum(ctx, "ListUTXOs", func(ctx context.Context, backend ChainBackend) (interface{}, string, error) {
+			utxos, err := backend.ListUTXOs(ctx, address)
+			return utxos, utxoSetSummary(utxos), err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result.([]*UTXO), nil
+	}
+
+	var utxos []*UTXO
+	err := m.failover(ctx, "ListUTXOs", func(ctx context.Context, backend ChainBackend) error {
+		var err error
+		utxos, err = backend.ListUTXOs(ctx, address)
+		return err
+	})
+	return utxos, err
+}
+
+func (m *MultiBackend) GetBalance(ctx context.Context, address string) (*GetBalanceResponse, error) {
+	var balance *GetBalanceResponse
+	err := m.failover(ctx, "GetBalance", func(ctx context.Context, backend ChainBackend) error {
+		var err error
+		balance, err = backend.GetBalance(ctx, address)
+		return err
+	})
+	return balance, err
+}
+
+func (m *MultiBackend) EstimateFee(ctx context.Context, targetBlocks int) (float64, error) {
+	var feeRate float64
+	err := m.failover(ctx, "EstimateFee", func(ctx context.Context, backend ChainBackend) error {
+		var err error
+		feeRate, err = backend.EstimateFee(ctx, targetBlocks)
+		return err
+	})
+	return feeRate, err
+}
+
+// Broadcast sends the transaction to the first available backend, or to all
+// of them in quorum mode. Every backend must return the txid of the
+// transaction. A backend that already has the transaction counts as a
+// success.
+func (m *MultiBackend) Broadcast(ctx context.Context, rawTxHex string) (string, error) {
+	tx, err := decodeTxHex(rawTxHex)
+	if err != nil {
+		return "", err
+	}
+	txid := tx.TxHash().String()
+
+	broadcast := func(ctx context.Context, backend ChainBackend) error {
+		result, err := backend.Broadcast(ctx, rawTxHex)
+		if err != nil {
+			// 다른 backend 에서 P2P 로 먼저 받은 경우
+			if isAlreadyKnown(err) {
+				return nil
+			}
+			return err
+		}
+		if result != txid {
+			return fmt.Errorf("backend returned txid %s for transaction %s", result, txid)
+		}
+		return nil
+	}
+
+	if m.Quorum > 1 {
+		_, err := m.quorum(ctx, "Broadcast", func(ctx context.Context, backend ChainBackend) (interface{}, string, error) {
+			return txid, txid, broadcast(ctx, backend)
+		})
+		if err != nil {
+			return "", err
+		}
+		return txid, nil
+	}
+
+	if err := m.failover(ctx, "Broadcast", broadcast); err != nil {
+		return "", err
+	}
+	return txid, nil
+}
+
+func (m *MultiBackend) GetTransaction(ctx context.Context, txid string) (*wire.MsgTx, error) {
+	var tx *wire.MsgTx
+	err := m.failover(ctx, "GetTransaction", func(ctx context.Context, backend ChainBackend) error {
+		var err error
+		tx, err = backend.GetTransaction(ctx, txid)
+		return err
+	})
+	return tx, err
+}
+
+func (m *MultiBackend) TipHeight(ctx context.Context) (int64, error) {
+	var height int64
+	err := m.failover(ctx, "TipHeight", func(ctx context.Context, backend ChainBackend) error {
+		var err error
+		height, err = backend.TipHeight(ctx)
+		return err
+	})
+	return height, err
+}
@@ -0,0 +1,264 @@
+package btcw
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// flakyBackend is a fakeBackend that returns err while it is set.
+type flakyBackend struct {
+	*fakeBackend
+
+	mu    sync.Mutex
+	err   error
+	calls int
+	// broadcastTxid 가 있으면 Broadcast 가 이 txid 를 돌려준다.
+	broadcastTxid string
+}
+
+func (f *flakyBackend) setErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *flakyBackend) call() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.err
+}
+
+func (f *flakyBackend) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *flakyBackend) ListUTXOs(ctx context.Context, address string) ([]*UTXO, error) {
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	return f.fakeBackend.ListUTXOs(ctx, address)
+}
+
+func (f *flakyBackend) Broadcast(ctx context.Context, rawTxHex string) (string, error) {
+	if err := f.call(); err != nil {
+		return "", err
+	}
+	if f.broadcastTxid != "" {
+		return f.broadcastTxid, nil
+	}
+	return f.fakeBackend.Broadcast(ctx, rawTxHex)
+}
+
+func (f *flakyBackend) TipHeight(ctx context.Context) (int64, error) {
+	if err := f.call(); err != nil {
+		return 0, err
+	}
+	return f.fakeBackend.TipHeight(ctx)
+}
+
+func newFlakyBackends(n int) ([]*flakyBackend, []NamedBackend) {
+	names := []string{"a", "b", "c", "d"}
+	flaky := make([]*flakyBackend, n)
+	named := make([]NamedBackend, n)
+	for i := range flaky {
+		flaky[i] = &flakyBackend{fakeBackend: newFakeBackend()}
+		named[i] = NamedBackend{Name: names[i], ChainBackend: flaky[i]}
+	}
+	return flaky, named
+}
+
+func TestMultiBackendFailover(t *testing.T) {
+	ctx := context.Background()
+	backends, named := newFlakyBackends(2)
+	backends[0].tipHeight = 100
+	backends[1].tipHeight = 101
+
+	now := time.Unix(1700000000, 0)
+	multi := NewMultiBackend(named...)
+	multi.FailureThreshold = 2
+	multi.now = func() time.Time { return now }
+
+	height, err := multi.TipHeight(ctx)
+	if err != nil || height != 100 {
+		t.Fatalf("unexpected tip height %d %v", height, err)
+	}
+
+	// rate limit 에 걸리면 다음 backend 로 넘어간다.
+	backends[0].setErr(&HTTPStatusError{StatusCode: http.StatusTooManyRequests})
+	for i := 0; i < 2; i++ {
+		height, err = multi.TipHeight(ctx)
+		if err != nil || height != 101 {
+			t.Fatalf("unexpected tip height %d %v", height, err)
+		}
+	}
+	// 연속으로 실패해서 circuit 이 열리면 a 는 호출하지 않는다.
+	if _, err := multi.TipHeight(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if backends[0].callCount() != 3 {
+		t.Errorf("expected 3 calls to a, got %d", backends[0].callCount())
+	}
+	if status := multi.Status(); status[0].Healthy || status[0].ConsecutiveFailures != 2 || !status[1].Healthy {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	// OpenDuration 이 지나면 다시 a 를 시도한다.
+	backends[0].setErr(nil)
+	now = now.Add(multi.OpenDuration)
+	height, err = multi.TipHeight(ctx)
+	if err != nil || height != 100 {
+		t.Fatalf("unexpected tip height %d %v", height, err)
+	}
+	if status := multi.Status(); !status[0].Healthy || status[0].ConsecutiveFailures != 0 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	// 모두 실패하면 ErrBackendUnavailable
+	backends[0].setErr(errors.New("connection refused"))
+	backends[1].setErr(errors.New("connection refused"))
+	_, err = multi.TipHeight(ctx)
+	if !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("expected ErrBackendUnavailable, got %v", err)
+	}
+	var multiErr *MultiBackendError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 2 {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestMultiBackendRejection(t *testing.T) {
+	backends, named := newFlakyBackends(2)
+	multi := NewMultiBackend(named...)
+
+	// 잘못된 요청은 다른 backend 에서도 실패하므로 넘어가지 않는다.
+	rejection := &HTTPStatusError{StatusCode: http.StatusBadRequest, Body: "invalid address"}
+	backends[0].setErr(rejection)
+	_, err := multi.ListUTXOs(context.Background(), "invalid")
+	if !errors.Is(err, rejection) {
+		t.Errorf("expected rejection, got %v", err)
+	}
+	if backends[1].callCount() != 0 {
+		t.Errorf("b should not be called")
+	}
+	if status := multi.Status(); status[0].ConsecutiveFailures != 0 {
+		t.Errorf("rejection should not count as failure %+v", status[0])
+	}
+}
+
+func TestMultiBackendQuorum(t *testing.T) {
+	ctx := context.Background()
+	chainParams := &chaincfg.TestNet3Params
+	address := "tb1qz40mujlemrru7t8t3yn3u5v3e9htmu5kektgme"
+	pkScript := addressScript(t, address, chainParams)
+
+	backends, named := newFlakyBackends(3)
+	for _, backend := range backends {
+		backend.utxos[address] = []*UTXO{newTestUTXO(0, 10000, pkScript), newTestUTXO(1, 20000, pkScript)}
+	}
+	multi := NewMultiBackend(named...)
+	multi.Quorum = 2
+	multi.FailureThreshold = 10
+
+	utxos, err := multi.ListUTXOs(ctx, address)
+	if err != nil || len(utxos) != 2 {
+		t.Fatalf("unexpected utxos %v %v", utxos, err)
+	}
+
+	// 하나가 죽어도 quorum 을 채우면 성공한다.
+	backends[2].setErr(errors.New("connection refused"))
+	if _, err := multi.ListUTXOs(ctx, address); err != nil {
+		t.Fatal(err)
+	}
+
+	// 다른 UTXO 를 주는 backend 가 있으면 믿지 않고 알린다.
+	backends[1].utxos[address] = []*UTXO{newTestUTXO(0, 10000, pkScript), newTestUTXO(1, 99999, pkScript)}
+	_, err = multi.ListUTXOs(ctx, address)
+	var quorumErr *QuorumError
+	if !errors.Is(err, ErrQuorumDisagreement) || !errors.As(err, &quorumErr) {
+		t.Fatalf("expected quorum error, got %v", err)
+	}
+	if len(quorumErr.Results) != 2 || quorumErr.Results["a"] == quorumErr.Results["b"] || len(quorumErr.Errors) != 1 {
+		t.Errorf("unexpected quorum error %+v", quorumErr)
+	}
+
+	// quorum 을 채우지 못하면 ErrBackendUnavailable
+	backends[1].setErr(errors.New("connection refused"))
+	if _, err := multi.ListUTXOs(ctx, address); !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("expected ErrBackendUnavailable, got %v", err)
+	}
+	backends[1].setErr(nil)
+	backends[2].setErr(nil)
+
+	// broadcast 는 모든 backend 로 보낸다.
+	tx := newTestSpendTx(t, []*UTXO{newTestUTXO(0, 10000, pkScript)}, pkScript, 9000)
+	var buf bytes.Buffer
+	tx.Serialize(&buf)
+	txHex := hex.EncodeToString(buf.Bytes())
+	txid, err := multi.Broadcast(ctx, txHex)
+	if err != nil || txid != tx.TxHash().String() {
+		t.Fatalf("unexpected broadcast result %s %v", txid, err)
+	}
+	for _, backend := range backends {
+		if len(backend.broadcast) != 1 {
+			t.Errorf("transaction should be sent to every backend")
+		}
+	}
+
+	// 한 곳에서만 거절되어도 알린다.
+	backends[2].setErr(&HTTPStatusError{StatusCode: http.StatusBadRequest, Body: "bad-txns-inputs-missingorspent"})
+	if _, err := multi.Broadcast(ctx, txHex); !errors.Is(err, ErrQuorumDisagreement) {
+		t.Errorf("expected quorum error, got %v", err)
+	}
+
+	// P2P 로 이미 받은 backend 는 성공으로 본다.
+	backends[2].setErr(&HTTPStatusError{StatusCode: http.StatusBadRequest, Body: `sendrawtransaction RPC error: {"code":-26,"message":"txn-already-known"}`})
+	if txid, err := multi.Broadcast(ctx, txHex); err != nil || txid != tx.TxHash().String() {
+		t.Errorf("already known transaction should be a success, got %s %v", txid, err)
+	}
+	backends[2].setErr(&btcjson.RPCError{Code: btcjson.ErrRPCVerifyAlreadyInChain, Message: "Transaction already in block chain"})
+	if _, err := multi.Broadcast(ctx, txHex); err != nil {
+		t.Errorf("transaction in the chain should be a success, got %v", err)
+	}
+	backends[2].setErr(nil)
+
+	// 다른 txid 를 돌려주는 backend 는 믿지 않는다.
+	backends[0].broadcastTxid = "0000000000000000000000000000000000000000000000000000000000000000"
+	backends[1].broadcastTxid = backends[0].broadcastTxid
+	if _, err := multi.Broadcast(ctx, txHex); !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("expected ErrBackendUnavailable for a wrong txid, got %v", err)
+	}
+}
+
+func TestMultiBackendCheckHealth(t *testing.T) {
+	backends, named := newFlakyBackends(3)
+	backends[0].tipHeight = 100
+	backends[1].tipHeight = 110
+	backends[2].setErr(errors.New("connection refused"))
+
+	multi := NewMultiBackend(named...)
+	statuses := multi.CheckHealth(context.Background())
+	if statuses[0].Healthy || !statuses[1].Healthy || statuses[2].Healthy {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+	if statuses[1].TipHeight != 110 {
+		t.Errorf("unexpected tip height %d", statuses[1].TipHeight)
+	}
+
+	// a 는 뒤처져 있으므로 b 가 응답한다.
+	height, err := multi.TipHeight(context.Background())
+	if err != nil || height != 110 {
+		t.Errorf("unexpected tip height %d %v", height, err)
+	}
+}
@@ -0,0 +1,156 @@
+package btcw
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestNet4Wire is the network magic of testnet4 (BIP94).
+const TestNet4Wire wire.BitcoinNet = 0x283f161c
+
+// testNet4GenesisCoinbaseTx is the coinbase of the testnet4 genesis block.
+var testNet4GenesisCoinbaseTx = func() *wire.MsgTx {
+	message := "03/May/2024 000000000000000000001ebd58c244970b3aa9d783bb001011fbe8ea8e98e00e"
+	sigScript := append([]byte{0x04, 0xff, 0xff, 0x00, 0x1d, 0x01, 0x04, 0x4c, byte(len(message))}, message...)
+	pkScript := append(append([]byte{0x21}, make([]byte, 33)...), 0xac)
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), sigScript, nil))
+	tx.AddTxOut(wire.NewTxOut(50*1e8, pkScript))
+	return tx
+}()
+
+var testNet4GenesisBlock = wire.MsgBlock{
+	Header: wire.BlockHeader{
+		Version:    1,
+		MerkleRoot: testNet4GenesisCoinbaseTx.TxHash(),
+		Timestamp:  time.Unix(1714777860, 0),
+		Bits:       0x1d00ffff,
+		Nonce:      393743547,
+	},
+	Transactions: []*wire.MsgTx{testNet4GenesisCoinbaseTx},
+}
+
+var testNet4GenesisHash = testNet4GenesisBlock.BlockHash()
+
+// TestNet4Params are the parameters of testnet4 (BIP94), which btcd does not
+// have yet. Addresses and keys are encoded like testnet3.
+var TestNet4Params = func() chaincfg.Params {
+	params := chaincfg.TestNet3Params
+	params.Name = "testnet4"
+	params.Net = TestNet4Wire
+	params.DefaultPort = "48333"
+	params.DNSSeeds = []chaincfg.DNSSeed{
+		{Host: "seed.testnet4.bitcoin.sprovoost.nl", HasFiltering: true},
+		{Host: "seed.testnet4.wiz.biz", HasFiltering: true},
+	}
+	params.GenesisBlock = &testNet4GenesisBlock
+	params.GenesisHash = &testNet4GenesisHash
+	params.BIP0034Height = 1
+	params.BIP0065Height = 1
+	params.BIP0066Height = 1
+	params.Checkpoints = nil
+	return params
+}()
+
+var networks = map[string]*chaincfg.Params{
+	"mainnet":  &chaincfg.MainNetParams,
+	"testnet3": &chaincfg.TestNet3Params,
+	"testnet4": &TestNet4Params,
+	"signet":   &chaincfg.SigNetParams,
+	"regtest":  &chaincfg.RegressionNetParams,
+	"simnet":   &chaincfg.SimNetParams,
+}
+
+// ParseNetwork returns the parameters of mainnet, testnet3, testnet4, signet,
+// regtest or simnet. The bitcoind chain names "main" and "test" are accepted
+// too.
+func ParseNetwork(name string) (*chaincfg.Params, error) {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "main", "bitcoin":
+		name = "mainnet"
+	case "test", "testnet":
+		name = "testnet3"
+	}
+	if net, ok := networks[name]; ok {
+		return net, nil
+	}
+	return nil, fmt.Errorf("unknown network %q", name)
+}
+
+// CustomSignetParams returns the parameters of a signet with its own block
+// challenge script, given in hex as in bitcoind's -signetchallenge.
+func CustomSignetParams(challengeHex string, seeds ...string) (*chaincfg.Params, error) {
+	challenge, err := hex.DecodeString(challengeHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signet challenge: %w", err)
+	}
+	dnsSeeds := make([]chaincfg.DNSSeed, len(seeds))
+	for i, seed := range seeds {
+		dnsSeeds[i] = chaincfg.DNSSeed{Host: seed}
+	}
+	params := chaincfg.CustomSignetParams(challenge, dnsSeeds)
+	return &params, nil
+}
+
+// NetworkFromEnv returns the network of the BTCW_NETWORK environment
+// variable, testnet3 if it is not set. If BTCW_SIGNET_CHALLENGE is set the
+// network is a custom signet with that challenge.
+func NetworkFromEnv() (*chaincfg.Params, error) {
+	if challenge := os.Getenv("BTCW_SIGNET_CHALLENGE"); challenge != "" {
+		if name := os.Getenv("BTCW_NETWORK"); name != "" && name != "signet" {
+			return nil, fmt.Errorf("BTCW_SIGNET_CHALLENGE is set for network %s", name)
+		}
+		return CustomSignetParams(challenge)
+	}
+	name := os.Getenv("BTCW_NETWORK")
+	if name == "" {
+		return &chaincfg.TestNet3Params, nil
+	}
+	return ParseNetwork(name)
+}
+
+// DefaultBackend returns the public backend of net: BlockCypher for mainnet
+// and testnet3, mempool.space for testnet4 and signet. Regtest and custom
+// signets have no public backend, use an RPCClient or an EsploraBackend of
+// your own there.
+func DefaultBackend(net *chaincfg.Params) (ChainBackend, error) {
+	switch net.Net {
+	case wire.MainNet, wire.TestNet3:
+		return NewBlockCypherBackend(net)
+	case TestNet4Wire, chaincfg.SigNetParams.Net:
+		return NewMempoolSpaceBackend(net)
+	}
+	return nil, fmt.Errorf("no public backend for network %s", net.Name)
+}
+
+// NewClientFromEnv returns a client for the network of NetworkFromEnv. The
+// backend is the node of BTCW_RPC_URL (see RPCClientFromEnv) if it is set,
+// else the Esplora API at BTCW_ESPLORA_URL if it is set, else DefaultBackend.
+func NewClientFromEnv() (*Client, error) {
+	net, err := NetworkFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var backend ChainBackend
+	switch {
+	case os.Getenv("BTCW_RPC_URL") != "":
+		backend, err = RPCClientFromEnv()
+	case os.Getenv("BTCW_ESPLORA_URL") != "":
+		backend = NewEsploraBackend(os.Getenv("BTCW_ESPLORA_URL"), net)
+	default:
+		backend, err = DefaultBackend(net)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(backend, net), nil
+}
@@ -0,0 +1,174 @@
+package btcw
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestParseNetwork(t *testing.T) {
+	tests := []struct {
+		name string
+		net  *chaincfg.Params
+	}{
+		{"mainnet", &chaincfg.MainNetParams},
+		{"main", &chaincfg.MainNetParams},
+		{"testnet3", &chaincfg.TestNet3Params},
+		{"test", &chaincfg.TestNet3Params},
+		{"Testnet4", &TestNet4Params},
+		{"signet", &chaincfg.SigNetParams},
+		{"regtest", &chaincfg.RegressionNetParams},
+	}
+	for _, test := range tests {
+		net, err := ParseNetwork(test.name)
+		if err != nil || net != test.net {
+			t.Errorf("%s: unexpected network %v %v", test.name, net, err)
+		}
+	}
+	if _, err := ParseNetwork("testnet5"); err == nil {
+		t.Errorf("unknown network should fail")
+	}
+}
+
+func TestTestNet4Params(t *testing.T) {
+	// bitcoind chainparams.cpp 의 testnet4 genesis block
+	if hash := TestNet4Params.GenesisHash.String(); hash != "00000000da84f2bafbbc53dee25a72ae507ff4914b867c565be350b0da8bf043" {
+		t.Errorf("unexpected genesis hash %s", hash)
+	}
+	if root := TestNet4Params.GenesisBlock.Header.MerkleRoot.String(); root != "7aa0a7ae1e223414cb807e40cd57e667b718e42aaf9306db9102fe28912b7b4e" {
+		t.Errorf("unexpected merkle root %s", root)
+	}
+	if chaincfg.TestNet3Params.Name != "testnet3" || chaincfg.TestNet3Params.Net != wire.TestNet3 {
+		t.Errorf("testnet3 params should not be changed")
+	}
+}
+
+func TestCustomSignetParams(t *testing.T) {
+	// 1-of-1 multisig challenge
+	challenge := "512103ad5e0edad18cb1f0fc0d28a3d4f1f3e445640337489abb10404f2d1e086be43051ae"
+	net, err := CustomSignetParams(challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.Net == chaincfg.SigNetParams.Net || net.Bech32HRPSegwit != "tb" {
+		t.Errorf("unexpected custom signet params %v %s", net.Net, net.Bech32HRPSegwit)
+	}
+	if _, err := DefaultBackend(net); err == nil {
+		t.Errorf("custom signet should not use the public signet backend")
+	}
+	if _, err := CustomSignetParams("zz"); err == nil {
+		t.Errorf("invalid challenge should fail")
+	}
+
+	t.Setenv("BTCW_NETWORK", "signet")
+	t.Setenv("BTCW_SIGNET_CHALLENGE", challenge)
+	envNet, err := NetworkFromEnv()
+	if err != nil || envNet.Net != net.Net {
+		t.Errorf("unexpected network from env %v %v", envNet, err)
+	}
+	t.Setenv("BTCW_NETWORK", "mainnet")
+	if _, err := NetworkFromEnv(); err == nil {
+		t.Errorf("signet challenge on mainnet should fail")
+	}
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	t.Setenv("BTCW_NETWORK", "")
+	client, err := NewClientFromEnv()
+	if err != nil || client.Net != &chaincfg.TestNet3Params {
+		t.Fatalf("unexpected default client %v %v", client, err)
+	}
+	if _, ok := client.Backend.(*BlockCypherBackend); !ok {
+		t.Errorf("unexpected backend %T", client.Backend)
+	}
+
+	t.Setenv("BTCW_NETWORK", "testnet4")
+	client, err = NewClientFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if esplora, ok := client.Backend.(*EsploraBackend); !ok || !strings.HasSuffix(esplora.BaseURL, "/testnet4/api") {
+		t.Errorf("unexpected backend %+v", client.Backend)
+	}
+
+	// regtest 는 노드를 지정해야 한다.
+	t.Setenv("BTCW_NETWORK", "regtest")
+	if _, err := NewClientFromEnv(); err == nil {
+		t.Errorf("regtest without a backend should fail")
+	}
+	t.Setenv("BTCW_RPC_URL", "http://127.0.0.1:18443")
+	client, err = NewClientFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rpc, ok := client.Backend.(*RPCClient); !ok || rpc.Net != &chaincfg.RegressionNetParams || client.Net != &chaincfg.RegressionNetParams {
+		t.Errorf("unexpected regtest client %+v", client.Backend)
+	}
+}
+
+func TestClientRegtest(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	fromAddress := wallet.GetSegwitAddress(net)
+	toAddress := wallet.GetTaprootAddress(net)
+	if !strings.HasPrefix(fromAddress, "bcrt1q") {
+		t.Fatalf("unexpected regtest address %s", fromAddress)
+	}
+
+	backend := newFakeBackend()
+	backend.utxos[fromAddress] = []*UTXO{newTestUTXO(0, 50000, nil)}
+	client := NewClient(backend, net)
+	signedHex, err := client.CreateTransferTransaction(context.Background(), fromAddress, toAddress, wallet.PrivateKeyToBytes(), 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, _ := decodeTxHex(signedHex)
+	verifyTransaction(t, tx, backend.utxos[fromAddress])
+
+	// testnet 주소로는 보낼 수 없다.
+	if _, err := client.CreateTransferTransaction(context.Background(), fromAddress, wallet.GetSegwitAddress(&chaincfg.TestNet3Params), wallet.PrivateKeyToBytes(), 10000); err == nil {
+		t.Errorf("sending to a testnet address on regtest should fail")
+	}
+}
+
+func TestDefaultClientFromEnv(t *testing.T) {
+	saved := DefaultClient
+	defer func() { DefaultClient = saved }()
+
+	// package 수준 함수도 BTCW_NETWORK 를 따른다.
+	DefaultClient = nil
+	t.Setenv("BTCW_NETWORK", "bogus")
+	if _, err := GetCurrentFeeRate(context.Background()); err == nil || DefaultClient != nil {
+		t.Errorf("invalid network should fail, got %v", err)
+	}
+	if _, err := TransferCoin(context.Background(), "", "", nil, 1000); err == nil {
+		t.Errorf("invalid network should fail")
+	}
+
+	t.Setenv("BTCW_NETWORK", "regtest")
+	t.Setenv("BTCW_ESPLORA_URL", "http://127.0.0.1:3002")
+	if _, err := GetUTXO(context.Background(), "bcrt1qz40mujlemrru7t8t3yn3u5v3e9htmu5kmy3y9x"); err == nil {
+		t.Errorf("expected a connection error")
+	}
+	if DefaultClient == nil || DefaultClient.Net != &chaincfg.RegressionNetParams {
+		t.Fatalf("unexpected default client %+v", DefaultClient)
+	}
+	// regtest 에는 BlockCypher 가 없다.
+	if _, err := GetAddressEndpoint(context.Background(), "bcrt1qz40mujlemrru7t8t3yn3u5v3e9htmu5kmy3y9x"); err == nil || !strings.Contains(err.Error(), "blockcypher does not support") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	DefaultClient = nil
+	t.Setenv("BTCW_NETWORK", "mainnet")
+	t.Setenv("BTCW_ESPLORA_URL", "")
+	client, err := defaultClient()
+	if err != nil || client.Net != &chaincfg.MainNetParams {
+		t.Errorf("unexpected default client %v %v", client, err)
+	}
+	if backend, ok := client.Backend.(*BlockCypherBackend); !ok || !strings.HasSuffix(backend.BaseURL, "/main") {
+		t.Errorf("unexpected backend %+v", client.Backend)
+	}
+}
@@ -0,0 +1,290 @@
+package btcw
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// RBFSequence is the input sequence number of the transactions built by the
+// client. It signals that the transaction can be replaced, BIP125.
+const RBFSequence = wire.MaxTxInSequenceNum - 2
+
+// maxBumpAttempts is how many times the fee rate of a replacement is raised
+// to pay for the relay of the replacement.
+const maxBumpAttempts = 3
+
+// ErrNotReplaceable is returned when bumping the fee of a transaction that
+// does not signal replace-by-fee.
+var ErrNotReplaceable = errors.New("transaction is not replaceable")
+
+// BumpedTransaction is a signed transaction replacing another one with a
+// higher fee.
+type BumpedTransaction struct {
+	Tx *wire.MsgTx
+	// Hex is the serialized transaction to broadcast.
+	Hex string
+	// Replaces is the txid of the replaced transaction.
+	Replaces    string
+	OriginalFee int64
+	Fee         int64
+}
+
+// Txid returns the id of the transaction.
+func (b *BumpedTransaction) Txid() string {
+	return b.Tx.TxHash().String()
+}
+
+// signalsRBF reports whether tx can be replaced, BIP125.
+func signalsRBF(tx *wire.MsgTx) bool {
+	for _, in := range tx.TxIn {
+		if in.Sequence < wire.MaxTxInSequenceNum-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// prevOuts returns the outputs spent by tx, looked up with the backend.
+func (c *Client) prevOuts(ctx context.Context, tx *wire.MsgTx) ([]*UTXO, error) {
+	parents := make(map[chainhash.Hash]*wire.MsgTx)
+	utxos := make([]*UTXO, len(tx.TxIn))
+	for i, in := range tx.TxIn {
+		hash := in.PreviousOutPoint.Hash
+		parent, ok := parents[hash]
+		if !ok {
+			var err error
+			if parent, err = c.GetTransaction(ctx, hash.String()); err != nil {
+				return nil, fmt.Errorf("input %d: %w", i, err)
+			}
+			parents[hash] = parent
+		}
+		index := in.PreviousOutPoint.Index
+		if int(index) >= len(parent.TxOut) {
+			return nil, fmt.Errorf("input %d: %s has no output %d", i, hash, index)
+		}
+		utxos[i] = &UTXO{
+			Hash:      hash.String(),
+			TxIndex:   int(index),
+			Amount:    big.NewInt(parent.TxOut[index].Value),
+			Spendable: true,
+			PKScript:  parent.TxOut[index].PkScript,
+		}
+	}
+	return utxos, nil
+}
+
+// CreateBumpFeeTransaction returns a signed transaction replacing txid, a
+// transaction from fromAddress, at newFeeRate sat/vB. changeIndex is the
+// index of the change output of txid, paying fromAddress, or -1 if it has
+// none. The other outputs are payments and are kept, the fee is taken from
+// the change. If the change is not enough, confirmed UTXOs of fromAddress are
+// added. The replacement pays more than
+// txid plus the minimum relay fee for its own size, BIP125. The added UTXOs
+// are reserved to the replacement, the reservation of txid is kept until
+// BumpFee broadcasts the replacement.
+func (c *Client) CreateBumpFeeTransaction(ctx context.Context, fromAddress string, txid string, changeIndex int, newFeeRate float64, privKey []byte) (*BumpedTransaction, error) {
+	original, err := c.GetTransaction(ctx, txid)
+	if err != nil {
+		return nil, err
+	}
+	if !signalsRBF(original) {
+		return nil, fmt.Errorf("%w: %s", ErrNotReplaceable, txid)
+	}
+	inputs, err := c.prevOuts(ctx, original)
+	if err != nil {
+		return nil, err
+	}
+
+	originalFee := -sumTxOut(original)
+	for _, utxo := range inputs {
+		originalFee += utxo.Amount.Int64()
+	}
+	if originalFeeRate := float64(originalFee) / float64(txVSize(original)); newFeeRate <= originalFeeRate {
+		return nil, fmt.Errorf("fee rate %.3f sat/vB is not higher than %.3f sat/vB of %s", newFeeRate, originalFeeRate, txid)
+	}
+
+	changeScript, err := payToAddressScript(fromAddress, c.Net)
+	if err != nil {
+		return nil, err
+	}
+	// 자기 주소로 보낸 payment 도 있으므로 잔돈은 호출하는 쪽이 알려준다.
+	if changeIndex < -1 || changeIndex >= len(original.TxOut) {
+		return nil, fmt.Errorf("%s has no output %d", txid, changeIndex)
+	}
+	if changeIndex >= 0 && !bytes.Equal(original.TxOut[changeIndex].PkScript, changeScript) {
+		return nil, fmt.Errorf("output %d of %s does not pay %s", changeIndex, txid, fromAddress)
+	}
+	var payments []*wire.TxOut
+	for i, out := range original.TxOut {
+		if i != changeIndex {
+			payments = append(payments, out)
+		}
+	}
+	changeOutput := wire.NewTxOut(0, changeScript)
+
+	// 추가할 input 은 확인된 UTXO 만 쓴다. 미확인 input 을 새로 넣으면 교체할 수 없다.
+	unspentTXOs, err := c.ListUTXOs(ctx, fromAddress)
+	if err != nil {
+		return nil, err
+	}
+	reserved, err := c.reservedOutpoints()
+	if err != nil {
+		return nil, err
+	}
+	spent := make(map[wire.OutPoint]bool, len(original.TxIn))
+	for _, in := range original.TxIn {
+		spent[in.PreviousOutPoint] = true
+	}
+	var candidates []*UTXO
+	for _, utxo := range unspentTXOs {
+		outpoint, err := utxo.outpoint()
+		if err != nil {
+			return nil, err
+		}
+		if utxo.BlockHeight > 0 && !spent[outpoint] && !reserved[outpoint] {
+			candidates = append(candidates, utxo)
+		}
+	}
+
+	pKey, _ := btcec.PrivKeyFromBytes(privKey)
+	vsize := keyInputVSize(pKey.PubKey())
+	inputVSizes := make(map[*UTXO]int64, len(inputs)+len(candidates))
+	for _, utxo := range append(inputs, candidates...) {
+		if inputVSizes[utxo], err = vsize(utxo.PKScript); err != nil {
+			return nil, fmt.Errorf("utxo %s:%d: %w", utxo.Hash, utxo.TxIndex, err)
+		}
+	}
+	changeSpendVSize, err := vsize(changeScript)
+	if err != nil {
+		return nil, err
+	}
+
+	// segwit 의 marker 와 flag 는 selectCoins 가 고른 input 에 따라 더한다.
+	var base TxSizeEstimator
+	var target int64
+	for _, payment := range payments {
+		base.AddOutput(payment.PkScript)
+		target += payment.Value
+	}
+
+	feeRate := newFeeRate
+	for attempt := 1; ; attempt++ {
+		selection, err := selectCoins(candidates, CoinSelectionParams{
+			Target:            target,
+			FeeRate:           feeRate,
+			BaseVSize:         base.VSize(),
+			InputVSize:        func(utxo *UTXO) int64 { return inputVSizes[utxo] },
+			ChangeOutputVSize: outputSize(changeScript),
+			ChangeSpendVSize:  changeSpendVSize,
+			MinChange:         dustThreshold(changeOutput),
+			Required:          inputs,
+			Rand:              c.Rand,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// BIP125: 교체 tx 는 원래 수수료에 더해 자기 크기만큼의 relay fee 를 내야 한다.
+		replacementVSize := base.VSize() + witnessOverhead(selection.UTXOs)
+		for _, utxo := range selection.UTXOs {
+			replacementVSize += inputVSizes[utxo]
+		}
+		if selection.Change > 0 {
+			replacementVSize += outputSize(changeScript)
+		}
+		minFee := originalFee + feeForVSize(MinRelayFeeRate, replacementVSize)
+		if selection.Fee < minFee {
+			if attempt == maxBumpAttempts {
+				return nil, fmt.Errorf("replacement fee %d is below %d", selection.Fee, minFee)
+			}
+			feeRate = float64(minFee) / float64(replacementVSize)
+			continue
+		}
+
+		// payment 순서는 그대로 두고 잔돈만 바꾼다.
+		tx := wire.NewMsgTx(original.Version)
+		tx.LockTime = original.LockTime
+		for _, utxo := range selection.UTXOs {
+			outpoint, err := utxo.outpoint()
+			if err != nil {
+				return nil, err
+			}
+			txIn := wire.NewTxIn(&outpoint, nil, nil)
+			txIn.Sequence = RBFSequence
+			tx.AddTxIn(txIn)
+		}
+		for _, payment := range payments {
+			tx.AddTxOut(wire.NewTxOut(payment.Value, payment.PkScript))
+		}
+		if selection.Change > 0 {
+			tx.AddTxOut(wire.NewTxOut(selection.Change, changeScript))
+		}
+		if err := signTransaction(tx, selection.UTXOs, pKey); err != nil {
+			return nil, err
+		}
+		signedHex, err := encodeTx(tx)
+		if err != nil {
+			return nil, err
+		}
+		if c.Reservations != nil {
+			// 원래 input 은 txid 가 예약하고 있다. 더한 input 만 교체 tx 로 예약한다.
+			var added []wire.OutPoint
+			for _, in := range tx.TxIn {
+				if !spent[in.PreviousOutPoint] {
+					added = append(added, in.PreviousOutPoint)
+				}
+			}
+			if err := c.Reservations.Reserve(tx.TxHash().String(), added, c.reservationTTL()); err != nil {
+				return nil, err
+			}
+		}
+		if changeIndex >= 0 && selection.Change == 0 {
+			log.Printf("bump fee %s: change output is removed", txid)
+		}
+		return &BumpedTransaction{
+			Tx:          tx,
+			Hex:         signedHex,
+			Replaces:    txid,
+			OriginalFee: originalFee,
+			Fee:         selection.Fee,
+		}, nil
+	}
+}
+
+// BumpFee replaces txid with a transaction paying newFeeRate sat/vB and
+// broadcasts it. See CreateBumpFeeTransaction. The reservation of txid is
+// moved to the replacement once it is broadcast. If that fails the broadcast
+// replacement is returned with the error.
+func (c *Client) BumpFee(ctx context.Context, fromAddress string, txid string, changeIndex int, newFeeRate float64, privKey []byte) (*BumpedTransaction, error) {
+	bumped, err := c.CreateBumpFeeTransaction(ctx, fromAddress, txid, changeIndex, newFeeRate, privKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+	// 원래 tx 는 아직 mempool 에 있을 수 있으므로 예약은 전파된 뒤에 넘긴다.
+	if _, err := c.SendRawTransaction(ctx, bumped.Hex); err != nil {
+		c.releaseReservation(bumped.Hex)
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
+	}
+	log.Printf("%s: replaced %s, txHash: %s", fromAddress, txid, bumped.Txid())
+	if c.Reservations != nil {
+		outpoints := make([]wire.OutPoint, len(bumped.Tx.TxIn))
+		for i, in := range bumped.Tx.TxIn {
+			outpoints[i] = in.PreviousOutPoint
+		}
+		if err := c.Reservations.Replace(txid, bumped.Txid(), outpoints, SpentReservationTTL); err != nil {
+			return bumped, fmt.Errorf("failed to move reservation %s to %s: %w", txid, bumped.Txid(), err)
+		}
+		if err := c.Reservations.MarkSpent(bumped.Txid(), SpentReservationTTL); err != nil {
+			return bumped, fmt.Errorf("failed to mark reservation %s as spent: %w", bumped.Txid(), err)
+		}
+	}
+	return bumped, nil
+}
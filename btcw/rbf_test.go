@@ -0,0 +1,237 @@
+package btcw
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// newTestFundingTx adds a confirmed transaction paying amounts to pkScript to
+// the backend and returns its outputs.
+func newTestFundingTx(backend *fakeBackend, pkScript []byte, amounts ...int64) []*UTXO {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: uint32(len(backend.txs))}, nil, nil))
+	for _, amount := range amounts {
+		tx.AddTxOut(wire.NewTxOut(amount, pkScript))
+	}
+	txid := tx.TxHash().String()
+	backend.txs[txid] = tx
+
+	utxos := make([]*UTXO, len(amounts))
+	for i, amount := range amounts {
+		utxos[i] = &UTXO{
+			Hash:        txid,
+			TxIndex:     i,
+			Amount:      big.NewInt(amount),
+			Spendable:   true,
+			PKScript:    pkScript,
+			BlockHeight: 100,
+		}
+	}
+	return utxos
+}
+
+func TestClientBumpFee(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	fromAddress := wallet.GetSegwitAddress(net)
+	fromScript := addressScript(t, fromAddress, net)
+	toAddress := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
+
+	tests := []struct {
+		name   string
+		amount int64
+		// inputs 는 교체 tx 의 input 수.
+		inputs int
+	}{
+		{"from change", 10000, 1},
+		// 잔돈 718 sat 으로는 부족해서 input 을 더한다.
+		{"add input", 29000, 2},
+	}
+	for _, test := range tests {
+		backend := newFakeBackend()
+		backend.feeRate = 2
+		utxos := newTestFundingTx(backend, fromScript, 30000, 20000)
+		// 미확인 UTXO 는 교체 tx 에 더하지 않는다.
+		unconfirmed := newTestUTXO(9, 100000, fromScript)
+		backend.utxos[fromAddress] = append([]*UTXO{unconfirmed}, utxos...)
+		client := NewClient(backend, net)
+		client.Rand = rand.New(rand.NewSource(1))
+		client.Reservations = NewMemoryReservationStore()
+
+		txid, err := client.TransferCoinWithOptions(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), test.amount,
+			TransferOptions{Exclude: []wire.OutPoint{testOutpoint(t, unconfirmed), testOutpoint(t, utxos[1])}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		original := backend.txs[txid]
+		for i, in := range original.TxIn {
+			if in.Sequence != RBFSequence {
+				t.Errorf("%s: input %d does not signal rbf", test.name, i)
+			}
+		}
+
+		// 전파가 실패하면 더한 input 의 예약만 풀린다.
+		rejecting := NewClient(&rejectingBackend{fakeBackend: backend}, net)
+		rejecting.Rand = rand.New(rand.NewSource(1))
+		rejecting.Reservations = client.Reservations
+		if _, err := rejecting.BumpFee(ctx, fromAddress, txid, 1, 20, wallet.PrivateKeyToBytes()); err == nil {
+			t.Fatalf("%s: expected the broadcast to fail", test.name)
+		}
+		if active, _ := client.Reservations.Reservations(); len(active) != 1 || active[0].ID != txid {
+			t.Errorf("%s: only the original reservation should be kept, got %+v", test.name, active)
+		}
+
+		// 전파하기 전에 더한 input 을 교체 tx 로 예약한다.
+		created, err := client.CreateBumpFeeTransaction(ctx, fromAddress, txid, 1, 20, wallet.PrivateKeyToBytes())
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		active, _ := client.Reservations.Reservations()
+		for _, r := range active {
+			if r.ID == created.Txid() && len(r.Outpoints) != test.inputs-1 {
+				t.Errorf("%s: unexpected reservation %+v", test.name, r)
+			}
+		}
+		if len(active) != 2 {
+			t.Errorf("%s: unexpected reservations %+v", test.name, active)
+		}
+		client.Reservations.Release(created.Txid())
+
+		client.Rand = rand.New(rand.NewSource(1))
+		bumped, err := client.BumpFee(ctx, fromAddress, txid, 1, 20, wallet.PrivateKeyToBytes())
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		tx := bumped.Tx
+		if bumped.Replaces != txid || len(backend.broadcast) != 2 || backend.broadcast[1] != bumped.Hex {
+			t.Errorf("%s: replacement should be broadcast", test.name)
+		}
+		if len(tx.TxIn) != test.inputs || tx.TxIn[0].PreviousOutPoint != original.TxIn[0].PreviousOutPoint {
+			t.Fatalf("%s: unexpected inputs %+v", test.name, tx.TxIn)
+		}
+		if tx.TxOut[0].Value != test.amount || !signalsRBF(tx) {
+			t.Errorf("%s: unexpected outputs %+v", test.name, tx.TxOut)
+		}
+
+		var inputTotal, outputTotal int64
+		for _, in := range tx.TxIn {
+			inputTotal += utxos[in.PreviousOutPoint.Index].Amount.Int64()
+		}
+		for _, out := range tx.TxOut {
+			outputTotal += out.Value
+		}
+		vsize := (txWeight(tx) + 3) / 4
+		if bumped.Fee != inputTotal-outputTotal || bumped.Fee < 20*vsize || bumped.Fee < bumped.OriginalFee+vsize {
+			t.Errorf("%s: fee %d for %d vB, original fee %d", test.name, bumped.Fee, vsize, bumped.OriginalFee)
+		}
+		verifyTransaction(t, tx, utxos[:len(tx.TxIn)])
+
+		// 예약은 전파된 교체 tx 로 넘어간다.
+		reservations, err := client.Reservations.Reservations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reservations) != 1 || reservations[0].ID != bumped.Txid() || len(reservations[0].Outpoints) != test.inputs || !reservations[0].Spent {
+			t.Errorf("%s: unexpected reservations %+v", test.name, reservations)
+		}
+	}
+}
+
+func TestClientBumpFeeSelfPayment(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	fromAddress := wallet.GetSegwitAddress(net)
+	toAddress := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
+
+	backend := newFakeBackend()
+	backend.feeRate = 2
+	utxos := newTestFundingTx(backend, addressScript(t, fromAddress, net), 30000, 20000)
+	backend.utxos[fromAddress] = utxos
+	client := NewClient(backend, net)
+
+	// 마지막 output 은 잔돈이 아니라 자기 주소로 보낸 payment 다.
+	batch, err := client.SendBatch(ctx, fromAddress, []Payment{{Address: toAddress, Amount: 10000}, {Address: fromAddress, Amount: 19500}}, wallet.PrivateKeyToBytes(),
+		TransferOptions{Exclude: []wire.OutPoint{testOutpoint(t, utxos[1])}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.ChangeIndex != -1 {
+		t.Fatalf("unexpected change output %d", batch.ChangeIndex)
+	}
+
+	bumped, err := client.BumpFee(ctx, fromAddress, batch.Txid(), batch.ChangeIndex, 10, wallet.PrivateKeyToBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := bumped.Tx
+	if len(tx.TxIn) != 2 || len(tx.TxOut) != 3 || tx.TxOut[0].Value != 10000 || tx.TxOut[1].Value != 19500 {
+		t.Errorf("payments should be kept, got %d inputs, outputs %+v", len(tx.TxIn), tx.TxOut)
+	}
+}
+
+func TestClientBumpFeeErrors(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	fromAddress := wallet.GetSegwitAddress(net)
+	toAddress := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
+
+	backend := newFakeBackend()
+	backend.feeRate = 5
+	backend.utxos[fromAddress] = newTestFundingTx(backend, addressScript(t, fromAddress, net), 30000)
+	client := NewClient(backend, net)
+	client.Reservations = NewMemoryReservationStore()
+
+	txid, err := client.TransferCoin(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateBumpFeeTransaction(ctx, fromAddress, txid, 1, 5, wallet.PrivateKeyToBytes()); err == nil {
+		t.Errorf("bumping to the same fee rate should fail")
+	}
+	if _, err := client.CreateBumpFeeTransaction(ctx, fromAddress, txid, 1, 1000, wallet.PrivateKeyToBytes()); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("expected insufficient funds, got %v", err)
+	}
+
+	for _, changeIndex := range []int{-2, 0, 2} {
+		if _, err := client.CreateBumpFeeTransaction(ctx, fromAddress, txid, changeIndex, 10, wallet.PrivateKeyToBytes()); err == nil {
+			t.Errorf("change index %d should fail", changeIndex)
+		}
+	}
+
+	// 교체 tx 가 거절되면 원래 tx 의 예약이 남는다.
+	rejecting := NewClient(&rejectingBackend{fakeBackend: backend}, net)
+	rejecting.Reservations = client.Reservations
+	if _, err := rejecting.BumpFee(ctx, fromAddress, txid, 1, 10, wallet.PrivateKeyToBytes()); err == nil {
+		t.Fatal("expected the broadcast to fail")
+	}
+	if active, _ := client.Reservations.Reservations(); len(active) != 1 || active[0].ID != txid || !active[0].Spent {
+		t.Errorf("original reservation should be kept, got %+v", active)
+	}
+
+	final := backend.txs[txid].Copy()
+	final.TxIn[0].Sequence = wire.MaxTxInSequenceNum
+	backend.txs[final.TxHash().String()] = final
+	if _, err := client.CreateBumpFeeTransaction(ctx, fromAddress, final.TxHash().String(), 1, 10, wallet.PrivateKeyToBytes()); !errors.Is(err, ErrNotReplaceable) {
+		t.Errorf("expected not replaceable, got %v", err)
+	}
+
+	// 원래 예약이 만료되어 다른 transfer 가 input 을 예약했으면 전파한 뒤에 오류를 돌려준다.
+	client.Reservations.Release(txid)
+	if err := client.Reservations.Reserve("other", []wire.OutPoint{backend.txs[txid].TxIn[0].PreviousOutPoint}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	bumped, err := client.BumpFee(ctx, fromAddress, txid, 1, 10, wallet.PrivateKeyToBytes())
+	if !errors.Is(err, ErrUTXOReserved) || bumped == nil || backend.broadcast[len(backend.broadcast)-1] != bumped.Hex {
+		t.Errorf("expected a reserved error after the broadcast, got %v", err)
+	}
+}
@@ -0,0 +1,402 @@
+package btcw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"go.etcd.io/bbolt"
+)
+
+// DefaultReservationTTL is how long the UTXOs of a transaction stay reserved
+// if it is not broadcast.
+const DefaultReservationTTL = 10 * time.Minute
+
+// SpentReservationTTL is how long the UTXOs of a transaction that was seen are
+// kept out of coin selection, until the backends stop listing them.
+const SpentReservationTTL = 24 * time.Hour
+
+// ErrUTXOReserved is matched by a ReservedError.
+var ErrUTXOReserved = errors.New("utxo is reserved")
+
+// ReservedError is returned when a UTXO is reserved by another transaction.
+type ReservedError struct {
+	Outpoint wire.OutPoint
+	// ID is the id of the reservation holding the UTXO.
+	ID string
+}
+
+func (e *ReservedError) Error() string {
+	return fmt.Sprintf("%v: %s by %s", ErrUTXOReserved, e.Outpoint, e.ID)
+}
+
+func (e *ReservedError) Is(target error) bool {
+	return target == ErrUTXOReserved
+}
+
+// Reservation is a lease of UTXOs, usually by the transaction spending them.
+type Reservation struct {
+	ID        string          `json:"id"`
+	Outpoints []wire.OutPoint `json:"outpoints"`
+	Expires   time.Time       `json:"expires"`
+	// Spent is set once the transaction was seen by a backend.
+	Spent bool `json:"spent"`
+}
+
+// ReservationStore keeps UTXOs from being spent by two transactions at once.
+// The Client reserves the UTXOs of each transaction it builds, with the txid
+// as id. Expired reservations are ignored.
+type ReservationStore interface {
+	// Reserve leases outpoints to id for ttl. If one of them is reserved
+	// already a ReservedError is returned and nothing is reserved. That is
+	// also the case if id itself is reserved: two transfers that built the
+	// same transaction must not both spend it.
+	Reserve(id string, outpoints []wire.OutPoint, ttl time.Duration) error
+	// Release removes the reservation of id.
+	Release(id string) error
+	// Replace removes the reservation of id and reserves outpoints to
+	// replacement for ttl in one step, e.g. when replacement replaced id in
+	// the mempool. Outpoints held by id or replacement do not conflict. If
+	// one is reserved by another id a ReservedError is returned and nothing
+	// is changed.
+	Replace(id string, replacement string, outpoints []wire.OutPoint, ttl time.Duration) error
+	// MarkSpent marks the reservation of id as spent and keeps it for ttl.
+	MarkSpent(id string, ttl time.Duration) error
+	// Reservations returns the reservations that have not expired.
+	Reservations() ([]Reservation, error)
+}
+
+// reservations is the state shared by the reservation stores.
+type reservations map[string]Reservation
+
+func (rs reservations) reserve(id string, outpoints []wire.OutPoint, expires time.Time, now time.Time) error {
+	if err := rs.checkReserved(outpoints, now); err != nil {
+		return err
+	}
+	rs[id] = Reservation{ID: id, Outpoints: outpoints, Expires: expires}
+	return nil
+}
+
+func (rs reservations) replace(id string, replacement string, outpoints []wire.OutPoint, expires time.Time, now time.Time) error {
+	if err := rs.checkReserved(outpoints, now, id, replacement); err != nil {
+		return err
+	}
+	delete(rs, id)
+	rs[replacement] = Reservation{ID: replacement, Outpoints: outpoints, Expires: expires}
+	return nil
+}
+
+// checkReserved returns a ReservedError if one of outpoints is reserved by a
+// reservation other than ignored.
+func (rs reservations) checkReserved(outpoints []wire.OutPoint, now time.Time, ignored ...string) error {
+	for otherID, r := range rs {
+		if !r.Expires.After(now) || containsString(ignored, otherID) {
+			continue
+		}
+		for _, reserved := range r.Outpoints {
+			for _, outpoint := range outpoints {
+				if outpoint == reserved {
+					return &ReservedError{Outpoint: outpoint, ID: otherID}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (rs reservations) markSpent(id string, expires time.Time) error {
+	r, ok := rs[id]
+	if !ok {
+		return fmt.Errorf("no reservation %s", id)
+	}
+	r.Spent = true
+	r.Expires = expires
+	rs[id] = r
+	return nil
+}
+
+// removeExpired deletes the expired reservations and returns the others
+// sorted by id.
+func (rs reservations) removeExpired(now time.Time) []Reservation {
+	var active []Reservation
+	for id, r := range rs {
+		if !r.Expires.After(now) {
+			delete(rs, id)
+			continue
+		}
+		active = append(active, r)
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].ID < active[j].ID })
+	return active
+}
+
+// MemoryReservationStore is a ReservationStore for the transfers of one
+// process.
+type MemoryReservationStore struct {
+	mu           sync.Mutex
+	reservations reservations
+	now          func() time.Time
+}
+
+func NewMemoryReservationStore() *MemoryReservationStore {
+	return &MemoryReservationStore{reservations: make(reservations), now: time.Now}
+}
+
+func (s *MemoryReservationStore) Reserve(id string, outpoints []wire.OutPoint, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.reservations.removeExpired(now)
+	return s.reservations.reserve(id, outpoints, now.Add(ttl), now)
+}
+
+func (s *MemoryReservationStore) Release(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.reservations, id)
+	return nil
+}
+
+func (s *MemoryReservationStore) Replace(id string, replacement string, outpoints []wire.OutPoint, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.reservations.removeExpired(now)
+	return s.reservations.replace(id, replacement, outpoints, now.Add(ttl), now)
+}
+
+func (s *MemoryReservationStore) MarkSpent(id string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reservations.markSpent(id, s.now().Add(ttl))
+}
+
+func (s *MemoryReservationStore) Reservations() ([]Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reservations.removeExpired(s.now()), nil
+}
+
+var reservationBucket = []byte("reservations")
+
+// BoltReservationStore is a ReservationStore saved in a bbolt database, so
+// reservations survive restarts. The file is locked by one process at a time,
+// workers of that process share the store.
+type BoltReservationStore struct {
+	db  *bbolt.DB
+	now func() time.Time
+}
+
+// OpenBoltReservationStore opens or creates the database at path.
+func OpenBoltReservationStore(path string) (*BoltReservationStore, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open reservation store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(reservationBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltReservationStore{db: db, now: time.Now}, nil
+}
+
+func (s *BoltReservationStore) Close() error {
+	return s.db.Close()
+}
+
+// update runs fn on the stored reservations and saves the result.
+func (s *BoltReservationStore) update(fn func(rs reservations, now time.Time) error) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(reservationBucket)
+		rs, err := loadReservations(bucket)
+		if err != nil {
+			return err
+		}
+		now := s.now()
+		rs.removeExpired(now)
+		if err := fn(rs, now); err != nil {
+			return err
+		}
+
+		// 바뀐 것만 찾기보다 bucket 을 다시 쓴다. reservation 은 많지 않다.
+		var stale [][]byte
+		err = bucket.ForEach(func(k, v []byte) error {
+			if _, ok := rs[string(k)]; !ok {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		for id, r := range rs {
+			data, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(id), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func loadReservations(bucket *bbolt.Bucket) (reservations, error) {
+	rs := make(reservations)
+	err := bucket.ForEach(func(k, v []byte) error {
+		var r Reservation
+		if err := json.Unmarshal(v, &r); err != nil {
+			return fmt.Errorf("invalid reservation %s: %w", k, err)
+		}
+		rs[string(k)] = r
+		return nil
+	})
+	return rs, err
+}
+
+func (s *BoltReservationStore) Reserve(id string, outpoints []wire.OutPoint, ttl time.Duration) error {
+	return s.update(func(rs reservations, now time.Time) error {
+		return rs.reserve(id, outpoints, now.Add(ttl), now)
+	})
+}
+
+func (s *BoltReservationStore) Release(id string) error {
+	return s.update(func(rs reservations, now time.Time) error {
+		delete(rs, id)
+		return nil
+	})
+}
+
+func (s *BoltReservationStore) Replace(id string, replacement string, outpoints []wire.OutPoint, ttl time.Duration) error {
+	return s.update(func(rs reservations, now time.Time) error {
+		return rs.replace(id, replacement, outpoints, now.Add(ttl), now)
+	})
+}
+
+func (s *BoltReservationStore) MarkSpent(id string, ttl time.Duration) error {
+	return s.update(func(rs reservations, now time.Time) error {
+		return rs.markSpent(id, now.Add(ttl))
+	})
+}
+
+func (s *BoltReservationStore) Reservations() ([]Reservation, error) {
+	var active []Reservation
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		rs, err := loadReservations(tx.Bucket(reservationBucket))
+		if err != nil {
+			return err
+		}
+		active = rs.removeExpired(s.now())
+		return nil
+	})
+	return active, err
+}
+
+func (c *Client) reservationTTL() time.Duration {
+	if c.ReservationTTL > 0 {
+		return c.ReservationTTL
+	}
+	return DefaultReservationTTL
+}
+
+// reservedOutpoints returns the outpoints reserved in c.Reservations.
+func (c *Client) reservedOutpoints() (map[wire.OutPoint]bool, error) {
+	if c.Reservations == nil {
+		return nil, nil
+	}
+	active, err := c.Reservations.Reservations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reservations: %w", err)
+	}
+	reserved := make(map[wire.OutPoint]bool)
+	for _, r := range active {
+		for _, outpoint := range r.Outpoints {
+			reserved[outpoint] = true
+		}
+	}
+	return reserved, nil
+}
+
+// releaseReservation releases the UTXOs of a transaction that could not be
+// broadcast, so the next transfer can spend them.
+func (c *Client) releaseReservation(signedHex string) {
+	if c.Reservations == nil {
+		return
+	}
+	tx, err := decodeTxHex(signedHex)
+	if err != nil {
+		return
+	}
+	if err := c.Reservations.Release(tx.TxHash().String()); err != nil {
+		log.Printf("failed to release reservation %s: %v", tx.TxHash(), err)
+	}
+}
+
+// markSpent keeps the UTXOs of a transaction that was broadcast reserved for
+// SpentReservationTTL, until the backends stop listing them.
+func (c *Client) markSpent(signedHex string) {
+	if c.Reservations == nil {
+		return
+	}
+	tx, err := decodeTxHex(signedHex)
+	if err != nil {
+		return
+	}
+	if err := c.Reservations.MarkSpent(tx.TxHash().String(), SpentReservationTTL); err != nil {
+		log.Printf("failed to mark reservation %s as spent: %v", tx.TxHash(), err)
+	}
+}
+
+// SyncReservations marks the reservations whose transaction the backend
+// knows as spent, so their UTXOs stay reserved until the backend stops
+// listing them.
+func (c *Client) SyncReservations(ctx context.Context) error {
+	if c.Reservations == nil {
+		return nil
+	}
+	active, err := c.Reservations.Reservations()
+	if err != nil {
+		return err
+	}
+	for _, r := range active {
+		if r.Spent {
+			continue
+		}
+		if _, err := c.GetTransaction(ctx, r.ID); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// 아직 전파되지 않았거나 backend 가 모른다. 만료될 때까지 기다린다.
+			continue
+		}
+		if err := c.Reservations.MarkSpent(r.ID, SpentReservationTTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
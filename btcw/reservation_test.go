@@ -0,0 +1,219 @@
+package btcw
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestReservationStores(t *testing.T) {
+	outpoints := []wire.OutPoint{
+		testOutpoint(t, newTestUTXO(0, 1000, nil)),
+		testOutpoint(t, newTestUTXO(1, 1000, nil)),
+		testOutpoint(t, newTestUTXO(2, 1000, nil)),
+	}
+
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+
+	memory := NewMemoryReservationStore()
+	memory.now = clock
+	bolt, err := OpenBoltReservationStore(filepath.Join(t.TempDir(), "reservations.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+	bolt.now = clock
+
+	tests := []struct {
+		name  string
+		store ReservationStore
+	}{
+		{"memory", memory},
+		{"bolt", bolt},
+	}
+	for _, test := range tests {
+		store := test.store
+		if err := store.Reserve("a", outpoints[:2], time.Minute); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		err := store.Reserve("b", outpoints[1:], time.Minute)
+		var reserved *ReservedError
+		if !errors.Is(err, ErrUTXOReserved) || !errors.As(err, &reserved) || reserved.ID != "a" || reserved.Outpoint != outpoints[1] {
+			t.Errorf("%s: expected a reserved error, got %v", test.name, err)
+		}
+
+		if err := store.Release("a"); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Reserve("b", outpoints[1:], time.Minute); err != nil {
+			t.Errorf("%s: released utxos should be reserved again: %v", test.name, err)
+		}
+		if err := store.MarkSpent("b", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.MarkSpent("c", time.Hour); err == nil {
+			t.Errorf("%s: marking an unknown reservation should fail", test.name)
+		}
+
+		// 만료된 reservation 은 무시한다.
+		if err := store.Reserve("c", outpoints[:1], time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(2 * time.Minute)
+		active, err := store.Reservations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(active) != 1 || active[0].ID != "b" || !active[0].Spent || len(active[0].Outpoints) != 2 || active[0].Outpoints[0] != outpoints[1] {
+			t.Errorf("%s: unexpected reservations %+v", test.name, active)
+		}
+		if err := store.Reserve("d", outpoints[:1], time.Minute); err != nil {
+			t.Errorf("%s: expired utxos should be reserved again: %v", test.name, err)
+		}
+	}
+}
+
+func TestReservationStoresReplace(t *testing.T) {
+	outpoints := []wire.OutPoint{
+		testOutpoint(t, newTestUTXO(0, 1000, nil)),
+		testOutpoint(t, newTestUTXO(1, 1000, nil)),
+		testOutpoint(t, newTestUTXO(2, 1000, nil)),
+	}
+	bolt, err := OpenBoltReservationStore(filepath.Join(t.TempDir(), "reservations.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+
+	for _, store := range []ReservationStore{NewMemoryReservationStore(), bolt} {
+		// 교체 tx 가 원래 input 과 더한 input 을 이어받는다.
+		store.Reserve("original", outpoints[:1], time.Minute)
+		store.Reserve("replacement", outpoints[1:2], time.Minute)
+		store.Reserve("other", outpoints[2:], time.Minute)
+		if err := store.Replace("original", "replacement", outpoints, time.Hour); !errors.Is(err, ErrUTXOReserved) {
+			t.Errorf("%T: expected a reserved error, got %v", store, err)
+		}
+		if active, _ := store.Reservations(); len(active) != 3 {
+			t.Errorf("%T: failed replace should change nothing, got %+v", store, active)
+		}
+		if err := store.Replace("original", "replacement", outpoints[:2], time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		active, _ := store.Reservations()
+		if len(active) != 2 || active[0].ID != "other" || active[1].ID != "replacement" || len(active[1].Outpoints) != 2 {
+			t.Errorf("%T: unexpected reservations %+v", store, active)
+		}
+	}
+}
+
+// rejectingBackend is a fakeBackend that rejects every transaction.
+type rejectingBackend struct {
+	*fakeBackend
+}
+
+func (r *rejectingBackend) Broadcast(ctx context.Context, rawTxHex string) (string, error) {
+	return "", &btcjson.RPCError{Code: btcjson.ErrRPCVerifyRejected, Message: "min relay fee not met"}
+}
+
+func TestClientReservations(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+	fromAddress := wallet.GetSegwitAddress(net)
+	toAddress := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
+
+	backend := newFakeBackend()
+	// PKScript 를 채워 두어야 client 들이 같은 UTXO 를 동시에 고치지 않는다.
+	utxos := newTestUTXOs(20000, 20000, 20000, 20000, 20000, 20000)
+	for _, utxo := range utxos {
+		utxo.PKScript = addressScript(t, fromAddress, net)
+	}
+	backend.utxos[fromAddress] = utxos
+	client := NewClient(backend, net)
+	client.Reservations = NewMemoryReservationStore()
+
+	// 동시에 만든 transaction 들이 같은 UTXO 를 쓰지 않는다.
+	var wg sync.WaitGroup
+	txs := make([]*wire.MsgTx, 4)
+	errs := make([]error, len(txs))
+	for i := range txs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			signedHex, err := client.CreateTransferTransaction(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 10000)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			txs[i], errs[i] = decodeTxHex(signedHex)
+		}(i)
+	}
+	wg.Wait()
+	spent := make(map[wire.OutPoint]bool)
+	for i, tx := range txs {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		for _, in := range tx.TxIn {
+			if spent[in.PreviousOutPoint] {
+				t.Errorf("utxo %s is spent twice", in.PreviousOutPoint)
+			}
+			spent[in.PreviousOutPoint] = true
+		}
+	}
+
+	_, err := client.CreateTransferTransaction(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 100000)
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("expected insufficient funds with reserved utxos, got %v", err)
+	}
+
+	// backend 가 아는 transaction 은 spent 가 된다.
+	backend.txs[txs[0].TxHash().String()] = txs[0]
+	if err := client.SyncReservations(ctx); err != nil {
+		t.Fatal(err)
+	}
+	active, _ := client.Reservations.Reservations()
+	for _, r := range active {
+		if r.Spent != (r.ID == txs[0].TxHash().String()) {
+			t.Errorf("unexpected reservation %+v", r)
+		}
+	}
+
+	// broadcast 한 transaction 은 SyncReservations 없이도 spent 가 된다.
+	client.Reservations = NewMemoryReservationStore()
+	txHash, err := client.TransferCoin(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := client.SendBatch(ctx, fromAddress, []Payment{{Address: toAddress, Amount: 10000}}, wallet.PrivateKeyToBytes(), TransferOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, _ = client.Reservations.Reservations()
+	if len(active) != 2 || !active[0].Spent || !active[1].Spent || active[0].Expires.Before(time.Now().Add(time.Hour)) {
+		t.Errorf("unexpected reservations %+v", active)
+	}
+	for _, r := range active {
+		if r.ID != txHash && r.ID != batch.Txid() {
+			t.Errorf("unexpected reservation %s", r.ID)
+		}
+	}
+
+	// broadcast 가 실패하면 예약을 푼다.
+	rejecting := NewClient(&rejectingBackend{fakeBackend: backend}, net)
+	rejecting.Reservations = NewMemoryReservationStore()
+	if _, err := rejecting.TransferCoin(ctx, fromAddress, toAddress, wallet.PrivateKeyToBytes(), 10000); err == nil {
+		t.Fatal("expected the broadcast to fail")
+	}
+	if active, _ := rejecting.Reservations.Reservations(); len(active) != 0 {
+		t.Errorf("reservations should be released, got %+v", active)
+	}
+}
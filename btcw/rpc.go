@@ -2,101 +2,508 @@ package btcw
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"math/big"
 	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
 )
 
-type CurrentFeeResp struct {
-	Result struct {
-		FeeRate float64 `json:"feerate"`
-		Blocks  int     `json:"blocks"`
-	} `json:"result"`
-	Error interface{} `json:"error"`
-	ID    string      `json:"id"`
+// DefaultMaxFeeRate is the maxfeerate in BTC/kvB passed to sendrawtransaction
+// and testmempoolaccept. It is the same as the bitcoind default.
+const DefaultMaxFeeRate = 0.10
+
+// RPCClient is a JSON-RPC client for a bitcoind node. It only uses wallet-less
+// methods so it works with nodes running -disablewallet.
+//
+// RPC errors are returned as *btcjson.RPCError, use errors.As or IsRPCError to
+// check the error code.
+type RPCClient struct {
+	URL string
+	// User and Password are used for basic auth. If CookiePath is set, the
+	// credentials are read from the cookie file on every request instead,
+	// because bitcoind writes a new cookie every time it starts.
+	User       string
+	Password   string
+	CookiePath string
+	HTTPClient *http.Client
+	// Net is the network of the node. Addresses of other networks are
+	// rejected before they are sent to the node.
+	Net *chaincfg.Params
+
+	id uint64
+}
+
+// NewRPCClient returns a client using rpcuser and rpcpassword.
+func NewRPCClient(url string, user string, password string, net *chaincfg.Params) *RPCClient {
+	return &RPCClient{URL: url, User: user, Password: password, HTTPClient: http.DefaultClient, Net: net}
+}
+
+// NewRPCClientWithCookie returns a client using the .cookie file of bitcoind,
+// e.g. ~/.bitcoin/testnet3/.cookie
+func NewRPCClientWithCookie(url string, cookiePath string, net *chaincfg.Params) *RPCClient {
+	return &RPCClient{URL: url, CookiePath: cookiePath, HTTPClient: http.DefaultClient, Net: net}
+}
+
+// RPCClientFromEnv returns a client configured by the BTCW_RPC_URL,
+// BTCW_RPC_USER, BTCW_RPC_PASSWORD and BTCW_RPC_COOKIE environment variables,
+// for the network of NetworkFromEnv.
+func RPCClientFromEnv() (*RPCClient, error) {
+	url := os.Getenv("BTCW_RPC_URL")
+	if url == "" {
+		return nil, errors.New("BTCW_RPC_URL is not set")
+	}
+	net, err := NetworkFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if cookiePath := os.Getenv("BTCW_RPC_COOKIE"); cookiePath != "" {
+		return NewRPCClientWithCookie(url, cookiePath, net), nil
+	}
+	return NewRPCClient(url, os.Getenv("BTCW_RPC_USER"), os.Getenv("BTCW_RPC_PASSWORD"), net), nil
 }
 
-type SendRawTransactionResp struct {
-	Result string      `json:"result"`
-	Error  interface{} `json:"error"`
-	ID     string      `json:"id"`
+// IsRPCError reports whether err is an RPC error with the given code.
+func IsRPCError(err error, code btcjson.RPCErrorCode) bool {
+	var rpcErr *btcjson.RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == code
 }
 
-func SendRawTransaction(signedhex string) (string, error) {
-	/*
-		response example
-		{
-			"result": "0x0d12f3a4b5c6d7e8f9",
-			"error": null,
-			"id": "1"
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage   `json:"result"`
+	Error  *btcjson.RPCError `json:"error"`
+	ID     *uint64           `json:"id"`
+}
+
+func (r *rpcResponse) decode(method string, result interface{}) error {
+	if r.Error != nil {
+		return fmt.Errorf("%s: %w", method, r.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(r.Result, result); err != nil {
+		return fmt.Errorf("%s: failed to decode result: %w", method, err)
+	}
+	return nil
+}
+
+// Call calls method and decodes the result into result, which may be nil.
+func (c *RPCClient) Call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	request := c.newRequest(method, params)
+	var response rpcResponse
+	if err := c.post(ctx, request, &response); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return response.decode(method, result)
+}
+
+// RPCBatchCall is a single call of a batch request.
+type RPCBatchCall struct {
+	Method string
+	Params []interface{}
+	// Result is decoded from the response if it is not nil.
+	Result interface{}
+	// Err is the error of this call, set by Batch.
+	Err error
+}
+
+// Batch sends calls in a single JSON-RPC batch request. The returned error is
+// only for the request itself, the error of each call is set to its Err.
+func (c *RPCClient) Batch(ctx context.Context, calls []*RPCBatchCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	requests := make([]*rpcRequest, len(calls))
+	callsByID := make(map[uint64]*RPCBatchCall, len(calls))
+	for i, call := range calls {
+		requests[i] = c.newRequest(call.Method, call.Params)
+		callsByID[requests[i].ID] = call
+	}
+
+	var responses []rpcResponse
+	if err := c.post(ctx, requests, &responses); err != nil {
+		return fmt.Errorf("batch: %w", err)
+	}
+
+	for i := range responses {
+		response := &responses[i]
+		if response.ID == nil {
+			continue
+		}
+		call, ok := callsByID[*response.ID]
+		if !ok {
+			continue
 		}
-	*/
-	rpcUrl := "https://bitcoin-testnet.g.allthatnode.com/archive/json_rpc/d9255f43f22848fea00de73650288453"
-	maxFeeRate := 0.10
-	bodyData := fmt.Sprintf("{\"jsonrpc\": \"1.0\", \"id\": \"1\", \"method\": \"sendrawtransaction\", \"params\": [\"%s\", %f]}", signedhex, maxFeeRate)
-	resp, err := http.Post(rpcUrl, "plain/text", bytes.NewBuffer([]byte(bodyData)))
+		call.Err = response.decode(call.Method, call.Result)
+		delete(callsByID, *response.ID)
+	}
+	for _, call := range callsByID {
+		call.Err = fmt.Errorf("%s: no response in batch", call.Method)
+	}
+	return nil
+}
+
+func (c *RPCClient) newRequest(method string, params []interface{}) *rpcRequest {
+	if params == nil {
+		params = []interface{}{}
+	}
+	return &rpcRequest{
+		JSONRPC: "1.0",
+		ID:      atomic.AddUint64(&c.id, 1),
+		Method:  method,
+		Params:  params,
+	}
+}
+
+func (c *RPCClient) post(ctx context.Context, request interface{}, response interface{}) error {
+	data, err := json.Marshal(request)
 	if err != nil {
-		return "", err
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	user, password, err := c.credentials()
+	if err != nil {
+		return err
+	}
+	if user != "" || password != "" {
+		req.SetBasicAuth(user, password)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return errors.New("rpc authentication failed")
+	}
+	// bitcoind answers RPC errors with status 500 or 404 and an error object
+	// in the body, so the body is decoded before checking the status.
+	if err := json.Unmarshal(body, response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *RPCClient) credentials() (string, string, error) {
+	if c.CookiePath == "" {
+		return c.User, c.Password, nil
+	}
+	cookie, err := os.ReadFile(c.CookiePath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read rpc cookie: %w", err)
+	}
+	user, password, ok := strings.Cut(strings.TrimSpace(string(cookie)), ":")
+	if !ok {
+		return "", "", errors.New("invalid rpc cookie")
+	}
+	return user, password, nil
+}
+
+func (c *RPCClient) GetBlockchainInfo(ctx context.Context) (*btcjson.GetBlockChainInfoResult, error) {
+	var result btcjson.GetBlockChainInfoResult
+	if err := c.Call(ctx, "getblockchaininfo", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *RPCClient) GetBlockCount(ctx context.Context) (int64, error) {
+	var result int64
+	if err := c.Call(ctx, "getblockcount", nil, &result); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+func (c *RPCClient) GetBlockHash(ctx context.Context, height int64) (string, error) {
+	var result string
+	if err := c.Call(ctx, "getblockhash", []interface{}{height}, &result); err != nil {
 		return "", err
 	}
+	return result, nil
+}
 
-	if resp.StatusCode != 200 {
-		bodyText := string(body)
-		log.Fatal(bodyText)
-		return "", fmt.Errorf("failed to send raw transaction. status code: %d text: %s", resp.StatusCode, bodyText)
+// GetBlock returns the block with verbosity 0.
+func (c *RPCClient) GetBlock(ctx context.Context, blockHash string) (*wire.MsgBlock, error) {
+	var result string
+	if err := c.Call(ctx, "getblock", []interface{}{blockHash, 0}, &result); err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(result)
+	if err != nil {
+		return nil, err
+	}
+	var block wire.MsgBlock
+	if err := block.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
 	}
+	return &block, nil
+}
+
+// GetBlockVerbose returns the block with verbosity 1, Tx is the list of txids.
+func (c *RPCClient) GetBlockVerbose(ctx context.Context, blockHash string) (*btcjson.GetBlockVerboseResult, error) {
+	var result btcjson.GetBlockVerboseResult
+	if err := c.Call(ctx, "getblock", []interface{}{blockHash, 1}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
 
-	var respData SendRawTransactionResp
-	err = json.Unmarshal(body, &respData)
+func (c *RPCClient) GetBlockHeader(ctx context.Context, blockHash string) (*wire.BlockHeader, error) {
+	var result string
+	if err := c.Call(ctx, "getblockheader", []interface{}{blockHash, false}, &result); err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(result)
 	if err != nil {
-		log.Fatal(err)
-		return "0", err
+		return nil, err
+	}
+	var header wire.BlockHeader
+	if err := header.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+func (c *RPCClient) GetBlockHeaderVerbose(ctx context.Context, blockHash string) (*btcjson.GetBlockHeaderVerboseResult, error) {
+	var result btcjson.GetBlockHeaderVerboseResult
+	if err := c.Call(ctx, "getblockheader", []interface{}{blockHash, true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetRawTransaction returns a mempool transaction, or a confirmed one if the
+// node runs with -txindex.
+func (c *RPCClient) GetRawTransaction(ctx context.Context, txid string) (*wire.MsgTx, error) {
+	var result string
+	if err := c.Call(ctx, "getrawtransaction", []interface{}{txid, false}, &result); err != nil {
+		return nil, err
+	}
+	return decodeTxHex(result)
+}
+
+func (c *RPCClient) GetRawTransactionVerbose(ctx context.Context, txid string) (*btcjson.TxRawResult, error) {
+	var result btcjson.TxRawResult
+	if err := c.Call(ctx, "getrawtransaction", []interface{}{txid, true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *RPCClient) GetMempoolEntry(ctx context.Context, txid string) (*btcjson.GetMempoolEntryResult, error) {
+	var result btcjson.GetMempoolEntryResult
+	if err := c.Call(ctx, "getmempoolentry", []interface{}{txid}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetTxOut returns the unspent output vout of txid, or nil if it is spent or
+// unknown. includeMempool also looks at unconfirmed transactions.
+func (c *RPCClient) GetTxOut(ctx context.Context, txid string, vout int, includeMempool bool) (*btcjson.GetTxOutResult, error) {
+	var result *btcjson.GetTxOutResult
+	if err := c.Call(ctx, "gettxout", []interface{}{txid, vout, includeMempool}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TestMempoolAccept checks whether rawTxs would be accepted to the mempool
+// without broadcasting them. maxFeeRate is in BTC/kvB.
+func (c *RPCClient) TestMempoolAccept(ctx context.Context, rawTxs []string, maxFeeRate float64) ([]*btcjson.TestMempoolAcceptResult, error) {
+	var result []*btcjson.TestMempoolAcceptResult
+	if err := c.Call(ctx, "testmempoolaccept", []interface{}{rawTxs, maxFeeRate}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ScanTxOutSetUnspent is an unspent output found by scantxoutset.
+type ScanTxOutSetUnspent struct {
+	TxID         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Desc         string  `json:"desc"`
+	Amount       float64 `json:"amount"`
+	Height       int64   `json:"height"`
+}
+
+// ScanTxOutSetResult is the result of scantxoutset start.
+type ScanTxOutSetResult struct {
+	Success     bool                  `json:"success"`
+	TxOuts      int64                 `json:"txouts"`
+	Height      int64                 `json:"height"`
+	BestBlock   string                `json:"bestblock"`
+	Unspents    []ScanTxOutSetUnspent `json:"unspents"`
+	TotalAmount float64               `json:"total_amount"`
+}
+
+// ScanTxOutSet scans the UTXO set for output descriptors, e.g.
+// "addr(tb1q...)". Only confirmed outputs are found.
+func (c *RPCClient) ScanTxOutSet(ctx context.Context, descriptors []string) (*ScanTxOutSetResult, error) {
+	var result ScanTxOutSetResult
+	if err := c.Call(ctx, "scantxoutset", []interface{}{"start", descriptors}, &result); err != nil {
+		return nil, err
 	}
+	return &result, nil
+}
+
+// EstimateSmartFee returns the fee rate estimate in BTC/kvB.
+func (c *RPCClient) EstimateSmartFee(ctx context.Context, confTarget int) (*btcjson.EstimateSmartFeeResult, error) {
+	var result btcjson.EstimateSmartFeeResult
+	if err := c.Call(ctx, "estimatesmartfee", []interface{}{confTarget}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SendRawTransaction broadcasts a signed transaction and returns its txid.
+// maxFeeRate is in BTC/kvB, 0 disables the check.
+func (c *RPCClient) SendRawTransaction(ctx context.Context, signedHex string, maxFeeRate float64) (string, error) {
+	var result string
+	if err := c.Call(ctx, "sendrawtransaction", []interface{}{signedHex, maxFeeRate}, &result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
 
-	return respData.Result, nil
+// scanAddress returns the unspent outputs of address, an address of c.Net.
+func (c *RPCClient) scanAddress(ctx context.Context, address string) (*ScanTxOutSetResult, error) {
+	if _, err := payToAddressScript(address, c.Net); err != nil {
+		return nil, err
+	}
+	return c.ScanTxOutSet(ctx, []string{"addr(" + address + ")"})
 }
 
-// GetCurrentFee gets the current fee in bitcoin
-func GetCurrentFee() (float64, error) {
-	/*
-		response example
-		{
-			"result": {
-				"feerate": 0.00024190,
-				"blocks": 100
-			},
-			"error": null,
-			"id": "1"
+func (c *RPCClient) ListUTXOs(ctx context.Context, address string) ([]*UTXO, error) {
+	result, err := c.scanAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	utxos := make([]*UTXO, 0, len(result.Unspents))
+	for _, unspent := range result.Unspents {
+		pkScript, err := hex.DecodeString(unspent.ScriptPubKey)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := btcutil.NewAmount(unspent.Amount)
+		if err != nil {
+			return nil, err
 		}
-	*/
+		utxos = append(utxos, &UTXO{
+			Hash:        unspent.TxID,
+			TxIndex:     int(unspent.Vout),
+			Amount:      big.NewInt(int64(amount)),
+			Spendable:   true,
+			PKScript:    pkScript,
+			BlockHeight: unspent.Height,
+		})
+	}
+	return utxos, nil
+}
 
-	rpcUrl := "https://bitcoin-testnet.g.allthatnode.com/archive/json_rpc/d9255f43f22848fea00de73650288453"
-	bodyData := "{\"jsonrpc\": \"1.0\", \"id\": \"1\", \"method\": \"estimatesmartfee\", \"params\": [1000]}"
-	resp, err := http.Post(rpcUrl, "plain/text", bytes.NewBuffer([]byte(bodyData)))
+// GetBalance returns the confirmed balance of address. bitcoind has no
+// address index, so NTx is the number of unspent outputs and an address whose
+// outputs are all spent looks unused.
+func (c *RPCClient) GetBalance(ctx context.Context, address string) (*GetBalanceResponse, error) {
+	result, err := c.scanAddress(ctx, address)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	defer resp.Body.Close()
+	amount, err := btcutil.NewAmount(result.TotalAmount)
+	if err != nil {
+		return nil, err
+	}
+	balance := int(amount)
+	nTx := len(result.Unspents)
+	return &GetBalanceResponse{
+		Address:       address,
+		TotalReceived: balance,
+		Balance:       balance,
+		FinalBalance:  balance,
+		NTx:           nTx,
+		FinalNTx:      nTx,
+	}, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// EstimateFee returns the estimatesmartfee fee rate in sat/vB.
+func (c *RPCClient) EstimateFee(ctx context.Context, targetBlocks int) (float64, error) {
+	result, err := c.EstimateSmartFee(ctx, targetBlocks)
 	if err != nil {
 		return 0, err
 	}
+	if result.FeeRate == nil {
+		return 0, fmt.Errorf("estimatesmartfee: no fee rate %v", result.Errors)
+	}
+	return btcPerKBToSatPerVByte(*result.FeeRate), nil
+}
+
+func (c *RPCClient) Broadcast(ctx context.Context, rawTxHex string) (string, error) {
+	return c.SendRawTransaction(ctx, rawTxHex, DefaultMaxFeeRate)
+}
 
-	var respData CurrentFeeResp
+func (c *RPCClient) GetTransaction(ctx context.Context, txid string) (*wire.MsgTx, error) {
+	return c.GetRawTransaction(ctx, txid)
+}
 
-	err = json.Unmarshal(body, &respData)
+func (c *RPCClient) TipHeight(ctx context.Context) (int64, error) {
+	return c.GetBlockCount(ctx)
+}
+
+// SendRawTransaction broadcasts signedhex through DefaultClient.
+func SendRawTransaction(ctx context.Context, signedhex string) (string, error) {
+	client, err := defaultClient()
 	if err != nil {
-		return 0, err
+		return "", err
 	}
+	return client.SendRawTransaction(ctx, signedhex)
+}
 
-	return respData.Result.FeeRate, nil
+// GetCurrentFee gets the current fee rate of DefaultClient in bitcoin per kvB.
+func GetCurrentFee(ctx context.Context) (float64, error) {
+	feeRate, err := GetCurrentFeeRate(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// sat/vB -> BTC/kvB
+	return feeRate * 1000 / btcutil.SatoshiPerBitcoin, nil
 }
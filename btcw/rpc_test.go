@@ -0,0 +1,225 @@
+package btcw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// newTestRPCServer returns a bitcoind-like JSON-RPC server. handle returns
+// the result or the error of a single call.
+func newTestRPCServer(t *testing.T, user, password string, handle func(method string, params []json.RawMessage) (interface{}, *btcjson.RPCError)) *httptest.Server {
+	type request struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	type response struct {
+		Result interface{}       `json:"result"`
+		Error  *btcjson.RPCError `json:"error"`
+		ID     json.RawMessage   `json:"id"`
+	}
+	call := func(r request) response {
+		result, rpcErr := handle(r.Method, r.Params)
+		return response{Result: result, Error: rpcErr, ID: r.ID}
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != user || p != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type %s", r.Header.Get("Content-Type"))
+		}
+
+		var raw json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Error(err)
+			return
+		}
+		if raw[0] == '[' {
+			var requests []request
+			json.Unmarshal(raw, &requests)
+			// bitcoind 와 같이 순서를 보장하지 않도록 거꾸로 응답한다.
+			responses := make([]response, len(requests))
+			for i, r := range requests {
+				responses[len(requests)-1-i] = call(r)
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+
+		var req request
+		json.Unmarshal(raw, &req)
+		resp := call(req)
+		if resp.Error != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func testRPCHandler(method string, params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+	switch method {
+	case "getblockcount":
+		return 2866877, nil
+	case "getblockchaininfo":
+		return map[string]interface{}{"chain": "test", "blocks": 2866877, "headers": 2866877, "bestblockhash": "000000000000000b"}, nil
+	case "estimatesmartfee":
+		return map[string]interface{}{"feerate": 0.00024190, "blocks": 6}, nil
+	case "getrawtransaction":
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidAddressOrKey, "No such mempool or blockchain transaction.")
+	case "scantxoutset":
+		return map[string]interface{}{
+			"success":      true,
+			"total_amount": 0.00033115,
+			"unspents": []map[string]interface{}{
+				{"txid": "bc26416ce0facd6733b26f5322b21f834ec9206eea9525ffd44e6c1102810fad", "vout": 1, "scriptPubKey": "76a91424fba4a8a8a3e0a1b6bbf2b4bbc1e6e7ed9ab4a088ac", "amount": 0.00017891, "height": 2866858},
+				{"txid": "ae2db75b9d7bfb9bc3c39e238e1479df3021b206039f67528024ab123990feb4", "vout": 0, "scriptPubKey": "76a91424fba4a8a8a3e0a1b6bbf2b4bbc1e6e7ed9ab4a088ac", "amount": 0.00015224, "height": 2822254},
+			},
+		}, nil
+	}
+	return nil, btcjson.NewRPCError(btcjson.ErrRPCMethodNotFound.Code, "Method not found")
+}
+
+func TestRPCClient(t *testing.T) {
+	server := newTestRPCServer(t, "user", "pass", testRPCHandler)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewRPCClient(server.URL, "user", "pass", &chaincfg.TestNet3Params)
+
+	info, err := client.GetBlockchainInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Chain != "test" || info.Blocks != 2866877 {
+		t.Errorf("unexpected blockchain info %+v", info)
+	}
+
+	// 0.00024190 BTC/kvB = 24.19 sat/vB
+	feeRate, err := client.EstimateFee(ctx, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feeRate < 24.189 || feeRate > 24.191 {
+		t.Errorf("unexpected fee rate %v", feeRate)
+	}
+
+	utxos, err := client.ListUTXOs(ctx, "miruDdUTqQv9eXMPPwXL73b9iy4gv8KeuH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utxos) != 2 || utxos[0].Amount.Int64() != 17891 || utxos[0].TxIndex != 1 || len(utxos[0].PKScript) != 25 {
+		t.Errorf("unexpected utxos %+v", utxos)
+	}
+	balance, err := client.GetBalance(ctx, "miruDdUTqQv9eXMPPwXL73b9iy4gv8KeuH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.FinalBalance != 33115 || balance.NTx != 2 {
+		t.Errorf("unexpected balance %+v", balance)
+	}
+	// 다른 network 의 주소는 노드에 보내지 않는다.
+	if _, err := client.ListUTXOs(ctx, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("expected invalid address, got %v", err)
+	}
+	if _, err := client.GetBalance(ctx, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("expected invalid address, got %v", err)
+	}
+
+	// RPC error code 는 btcjson.RPCError 로 반환된다.
+	_, err = client.GetTransaction(ctx, "bc26416ce0facd6733b26f5322b21f834ec9206eea9525ffd44e6c1102810fad")
+	if !IsRPCError(err, btcjson.ErrRPCInvalidAddressOrKey) {
+		t.Errorf("expected ErrRPCInvalidAddressOrKey, got %v", err)
+	}
+
+	wrongPassword := NewRPCClient(server.URL, "user", "wrong", &chaincfg.TestNet3Params)
+	if _, err := wrongPassword.TipHeight(ctx); err == nil {
+		t.Errorf("wrong password should fail")
+	}
+}
+
+func TestRPCClientBatch(t *testing.T) {
+	server := newTestRPCServer(t, "user", "pass", testRPCHandler)
+	defer server.Close()
+
+	client := NewRPCClient(server.URL, "user", "pass", &chaincfg.TestNet3Params)
+	var height int64
+	var fee btcjson.EstimateSmartFeeResult
+	calls := []*RPCBatchCall{
+		{Method: "getblockcount", Result: &height},
+		{Method: "getrawtransaction", Params: []interface{}{"00", true}},
+		{Method: "estimatesmartfee", Params: []interface{}{6}, Result: &fee},
+	}
+	if err := client.Batch(context.Background(), calls); err != nil {
+		t.Fatal(err)
+	}
+	if calls[0].Err != nil || height != 2866877 {
+		t.Errorf("unexpected getblockcount %d %v", height, calls[0].Err)
+	}
+	if !IsRPCError(calls[1].Err, btcjson.ErrRPCInvalidAddressOrKey) {
+		t.Errorf("expected ErrRPCInvalidAddressOrKey, got %v", calls[1].Err)
+	}
+	if calls[2].Err != nil || fee.FeeRate == nil || fee.Blocks != 6 {
+		t.Errorf("unexpected estimatesmartfee %+v %v", fee, calls[2].Err)
+	}
+}
+
+func TestRPCClientCookie(t *testing.T) {
+	server := newTestRPCServer(t, "__cookie__", "secret", testRPCHandler)
+	defer server.Close()
+
+	cookiePath := filepath.Join(t.TempDir(), ".cookie")
+	if err := os.WriteFile(cookiePath, []byte("__cookie__:secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewRPCClientWithCookie(server.URL, cookiePath, &chaincfg.TestNet3Params)
+	height, err := client.TipHeight(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 2866877 {
+		t.Errorf("unexpected height %d", height)
+	}
+
+	// bitcoind 가 재시작하며 cookie 를 바꾸면 새 cookie 를 읽어야 한다.
+	os.WriteFile(cookiePath, []byte("__cookie__:other"), 0600)
+	if _, err := client.TipHeight(context.Background()); err == nil {
+		t.Errorf("expected authentication error with a new cookie")
+	}
+}
+
+func TestPackageLevelRPCFunctions(t *testing.T) {
+	// package 수준 함수는 TransferCoin 과 같은 DefaultClient 를 쓴다.
+	backend := newFakeBackend()
+	backend.feeRate = 12.5
+	defaultClient := DefaultClient
+	DefaultClient = NewClient(backend, &chaincfg.TestNet3Params)
+	defer func() { DefaultClient = defaultClient }()
+
+	feeRate, err := GetCurrentFee(context.Background())
+	if err != nil || feeRate != 0.000125 {
+		t.Errorf("unexpected fee %v %v", feeRate, err)
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, nil))
+	signedHex, _ := encodeTx(tx)
+	txid, err := SendRawTransaction(context.Background(), signedHex)
+	if err != nil || txid != tx.TxHash().String() || len(backend.broadcast) != 1 {
+		t.Errorf("unexpected broadcast %s %v", txid, err)
+	}
+}
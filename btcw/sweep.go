@@ -0,0 +1,197 @@
+package btcw
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// SweepTransaction is a signed transaction sending every UTXO of a key to a
+// single output.
+type SweepTransaction struct {
+	Tx *wire.MsgTx
+	// Hex is the serialized transaction to broadcast.
+	Hex string
+	// UTXOs are the spent outputs and Skipped the outputs worth less than the
+	// fee to spend them.
+	UTXOs   []*UTXO
+	Skipped []*UTXO
+	// Amount is the value of the output.
+	Amount int64
+	Fee    int64
+}
+
+// Txid returns the id of the transaction.
+func (s *SweepTransaction) Txid() string {
+	return s.Tx.TxHash().String()
+}
+
+// sweepWallet parses source, a WIF or a hex encoded private key.
+func sweepWallet(source string, net *chaincfg.Params) (*Wallet, error) {
+	if _, err := btcutil.DecodeWIF(source); err == nil {
+		wallet, err := WalletFromWIF(source)
+		if err != nil {
+			return nil, err
+		}
+		if wallet.Net.PrivateKeyID != net.PrivateKeyID {
+			return nil, fmt.Errorf("WIF is not for network %s", net.Name)
+		}
+		return wallet, nil
+	}
+	wallet, err := GetWalletFromPrivateKeyString(source)
+	if err != nil {
+		return nil, fmt.Errorf("source is neither a WIF nor a hex private key: %w", err)
+	}
+	return wallet, nil
+}
+
+// sweepAddresses returns every address the key of wallet can spend from:
+// P2PKH with the compressed and the uncompressed public key, P2WPKH,
+// P2SH-P2WPKH and P2TR.
+func sweepAddresses(wallet *Wallet, net *chaincfg.Params) ([]string, error) {
+	compressed, err := GetLegacyAddressFromPubKeyBytes(wallet.SerializePubKeyCompressed(), net)
+	if err != nil {
+		return nil, err
+	}
+	uncompressed, err := GetLegacyAddressFromPubKeyBytes(wallet.SerializePubKeyUncompressed(), net)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		compressed,
+		uncompressed,
+		wallet.GetSegwitAddress(net),
+		wallet.GetNestedSegwitAddress(net),
+		wallet.GetTaprootAddress(net),
+	}, nil
+}
+
+// CreateSweepTransaction returns a signed transaction sending all UTXOs of
+// source, a WIF or a hex encoded private key, to destination without change.
+// UTXOs worth less than the fee to spend them at feeRate sat/vB are skipped.
+// If feeRate is 0 the current fee rate is used, a lower rate than
+// MinRelayFeeRate is raised to it. ErrTxTooLarge is returned if the UTXOs do
+// not fit in a standard transaction.
+func (c *Client) CreateSweepTransaction(ctx context.Context, source string, destination string, feeRate float64) (*SweepTransaction, error) {
+	if feeRate < 0 {
+		return nil, fmt.Errorf("invalid fee rate %v", feeRate)
+	}
+	wallet, err := sweepWallet(source, c.Net)
+	if err != nil {
+		return nil, err
+	}
+	destScript, err := payToAddressScript(destination, c.Net)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := sweepAddresses(wallet, c.Net)
+	if err != nil {
+		return nil, err
+	}
+	if feeRate == 0 {
+		if feeRate, err = c.GetCurrentFeeRate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	feeRate = math.Max(feeRate, MinRelayFeeRate)
+	reserved, err := c.reservedOutpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	sweep := &SweepTransaction{}
+	uncompressedScript, err := payToAddressScript(addresses[1], c.Net)
+	if err != nil {
+		return nil, err
+	}
+	var estimator TxSizeEstimator
+	var total int64
+	var outpoints []wire.OutPoint
+	for _, address := range addresses {
+		utxos, err := c.ListUTXOs(ctx, address)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", address, err)
+		}
+		for _, utxo := range utxos {
+			outpoint, err := utxo.outpoint()
+			if err != nil {
+				return nil, err
+			}
+			if reserved[outpoint] {
+				continue
+			}
+			compressed := !bytes.Equal(utxo.PKScript, uncompressedScript)
+			vsize, err := inputVSize(utxo.PKScript, compressed)
+			if err != nil {
+				return nil, fmt.Errorf("utxo %s:%d: %w", utxo.Hash, utxo.TxIndex, err)
+			}
+			if utxo.Amount.Int64() <= feeForVSize(feeRate, vsize) {
+				sweep.Skipped = append(sweep.Skipped, utxo)
+				continue
+			}
+			if txscript.GetScriptClass(utxo.PKScript) == txscript.PubKeyHashTy {
+				estimator.AddP2PKHInput(compressed)
+			} else if err := estimator.AddInput(utxo.PKScript); err != nil {
+				return nil, err
+			}
+			sweep.UTXOs = append(sweep.UTXOs, utxo)
+			outpoints = append(outpoints, outpoint)
+			total += utxo.Amount.Int64()
+		}
+	}
+	estimator.AddOutput(destScript)
+	if weight := estimator.Weight(); weight > maxStandardTxWeight {
+		return nil, fmt.Errorf("%w: %d inputs weigh %d WU, more than %d", ErrTxTooLarge, len(sweep.UTXOs), weight, maxStandardTxWeight)
+	}
+
+	sweep.Fee = estimator.Fee(feeRate)
+	output := wire.NewTxOut(total-sweep.Fee, destScript)
+	if threshold := dustThreshold(output); output.Value < threshold {
+		return nil, &InsufficientFundsError{Available: total, Required: sweep.Fee + threshold}
+	}
+	sweep.Amount = output.Value
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for i := range outpoints {
+		txIn := wire.NewTxIn(&outpoints[i], nil, nil)
+		txIn.Sequence = RBFSequence
+		tx.AddTxIn(txIn)
+	}
+	tx.AddTxOut(output)
+	if err := signTransaction(tx, sweep.UTXOs, wallet.PrivateKey); err != nil {
+		return nil, err
+	}
+	if sweep.Hex, err = encodeTx(tx); err != nil {
+		return nil, err
+	}
+	sweep.Tx = tx
+	if c.Reservations != nil {
+		if err := c.Reservations.Reserve(tx.TxHash().String(), outpoints, c.reservationTTL()); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("sweep: %d inputs, %d skipped, amount %d, fee %d", len(sweep.UTXOs), len(sweep.Skipped), sweep.Amount, sweep.Fee)
+	return sweep, nil
+}
+
+// Sweep sends all UTXOs of source to destination. See CreateSweepTransaction.
+func (c *Client) Sweep(ctx context.Context, source string, destination string, feeRate float64) (*SweepTransaction, error) {
+	sweep, err := c.CreateSweepTransaction(ctx, source, destination, feeRate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+	if _, err := c.SendRawTransaction(ctx, sweep.Hex); err != nil {
+		c.releaseReservation(sweep.Hex)
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
+	}
+	c.markSpent(sweep.Hex)
+	log.Printf("swept to %s, txHash: %s", destination, sweep.Txid())
+	return sweep, nil
+}
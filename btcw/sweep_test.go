@@ -0,0 +1,110 @@
+package btcw
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestClientSweep(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725")
+	destination := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
+	addresses, err := sweepAddresses(wallet, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := newFakeBackend()
+	for i, address := range addresses {
+		backend.utxos[address] = []*UTXO{newTestUTXO(i, int64(10000*(i+1)), nil)}
+	}
+	// P2PKH input 을 5 sat/vB 로 쓰는 비용 740 sat 보다 작으므로 쓰지 않는다.
+	dust := newTestUTXO(9, 700, nil)
+	backend.utxos[addresses[0]] = append(backend.utxos[addresses[0]], dust)
+	client := NewClient(backend, net)
+
+	// 비압축 WIF 로 가져와도 모든 종류의 주소를 찾는다.
+	wif, _ := wallet.ToWIF(net, false)
+	sweep, err := client.Sweep(ctx, wif, destination, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := sweep.Tx
+	if len(backend.broadcast) != 1 || backend.broadcast[0] != sweep.Hex {
+		t.Errorf("sweep should be broadcast")
+	}
+	if len(tx.TxIn) != len(addresses) || len(sweep.Skipped) != 1 || sweep.Skipped[0] != dust {
+		t.Fatalf("unexpected inputs %d, skipped %d", len(tx.TxIn), len(sweep.Skipped))
+	}
+	if len(tx.TxOut) != 1 || tx.TxOut[0].Value != sweep.Amount || sweep.Amount+sweep.Fee != 150000 {
+		t.Errorf("unexpected outputs %+v, fee %d", tx.TxOut, sweep.Fee)
+	}
+	if vsize := txVSize(tx); sweep.Fee < 5*vsize || sweep.Fee > 5*(vsize+int64(len(tx.TxIn))) {
+		t.Errorf("fee %d for %d vB", sweep.Fee, vsize)
+	}
+	verifyTransaction(t, tx, sweep.UTXOs)
+
+	// hex 개인키도 받는다.
+	sweep, err = client.CreateSweepTransaction(ctx, "18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725", destination, 5)
+	if err != nil || len(sweep.UTXOs) != len(addresses) {
+		t.Errorf("unexpected sweep %v", err)
+	}
+}
+
+func TestClientSweepErrors(t *testing.T) {
+	ctx := context.Background()
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725")
+	destination := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
+	mainnetWIF, _ := wallet.ToWIF(&chaincfg.MainNetParams, true)
+
+	backend := newFakeBackend()
+	backend.utxos[wallet.GetSegwitAddress(net)] = newTestUTXOs(300)
+	client := NewClient(backend, net)
+
+	tests := []struct {
+		name   string
+		source string
+		err    error
+	}{
+		{"mainnet wif", mainnetWIF, nil},
+		{"invalid source", "not a key", nil},
+		{"only dust", "18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725", ErrInsufficientFunds},
+	}
+	for _, test := range tests {
+		_, err := client.CreateSweepTransaction(ctx, test.source, destination, 5)
+		if err == nil || (test.err != nil && !errors.Is(err, test.err)) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+
+	privKeyHex := "18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725"
+	if _, err := client.CreateSweepTransaction(ctx, privKeyHex, destination, -1); err == nil {
+		t.Errorf("negative fee rate should fail")
+	}
+
+	// 0.1 sat/vB 는 최소 relay fee 로 올린다.
+	backend.utxos[wallet.GetSegwitAddress(net)] = newTestUTXOs(10000)
+	sweep, err := client.CreateSweepTransaction(ctx, privKeyHex, destination, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vsize := txVSize(sweep.Tx); sweep.Fee < vsize {
+		t.Errorf("fee %d is below the minimum relay fee for %d vB", sweep.Fee, vsize)
+	}
+
+	// P2PKH input 700 개는 표준 weight 400000 을 넘는다.
+	compressed, _ := GetLegacyAddressFromPubKeyBytes(wallet.SerializePubKeyCompressed(), net)
+	amounts := make([]int64, 700)
+	for i := range amounts {
+		amounts[i] = 10000
+	}
+	backend.utxos[compressed] = newTestUTXOs(amounts...)
+	if _, err := client.CreateSweepTransaction(ctx, privKeyHex, destination, 5); !errors.Is(err, ErrTxTooLarge) {
+		t.Errorf("expected too large, got %v", err)
+	}
+}
@@ -2,19 +2,16 @@ package btcw
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
 	"math/big"
-	"math/rand"
-	"sort"
-	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil"
-	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 )
@@ -26,287 +23,355 @@ type UTXO struct {
 	Amount    *big.Int
 	Spendable bool
 	PKScript  []byte
+	// BlockHeight is the height of the block the output was confirmed in, 0
+	// if it is unconfirmed.
+	BlockHeight int64
 }
 
-func CreateTransferTransaction(fromAddress string, toAddress string, privKey []byte, amountSatoshi int64) (string, error) {
-	fmt.Printf("%s -> %s\n", fromAddress, toAddress)
-	unspentTXOs, err := GetUTXO(fromAddress)
+func CreateTransferTransaction(ctx context.Context, fromAddress string, toAddress string, privKey []byte, amountSatoshi int64) (string, error) {
+	client, err := defaultClient()
 	if err != nil {
-		log.Fatal(err)
 		return "", err
 	}
+	return client.CreateTransferTransaction(ctx, fromAddress, toAddress, privKey, amountSatoshi)
+}
+
+// CreateTransferTransaction returns a signed transaction that sends
+// amountSatoshi from fromAddress to toAddress, with the change going back to
+// fromAddress. A change below the dust threshold is left to the fee.
+func (c *Client) CreateTransferTransaction(ctx context.Context, fromAddress string, toAddress string, privKey []byte, amountSatoshi int64) (string, error) {
+	return c.CreateTransferTransactionWithOptions(ctx, fromAddress, toAddress, privKey, amountSatoshi, TransferOptions{})
+}
 
-	// if fromAddress UTXO is empty, return
-	if len(unspentTXOs) == 0 {
-		err := errors.New("fromAddress UTXO is empty")
-		log.Fatal(err)
+// CreateTransferTransactionWithOptions is CreateTransferTransaction spending
+// only the UTXOs opts allow. A CoinControlError is returned if a UTXO of
+// opts.Include can not be spent.
+func (c *Client) CreateTransferTransactionWithOptions(ctx context.Context, fromAddress string, toAddress string, privKey []byte, amountSatoshi int64, opts TransferOptions) (string, error) {
+	chainParams := c.Net
+	destScript, err := payToAddressScript(toAddress, chainParams)
+	if err != nil {
 		return "", err
 	}
-
-	chainParams := &chaincfg.TestNet3Params
-	amountToSend := big.NewInt(amountSatoshi) // amount to send in satoshis (0.01 btc)
-	feeRate, err := GetCurrentFeeRate()
+	changeSendToScript, err := payToAddressScript(fromAddress, chainParams)
 	if err != nil {
-		log.Fatal(err)
 		return "", err
 	}
 
-	unspentTXOs, UTXOsAmount, err := marshalUTXOs(unspentTXOs, amountToSend, feeRate)
+	// tx out to send btc to user
+	destOutput := wire.NewTxOut(amountSatoshi, destScript)
+	if threshold := dustThreshold(destOutput); amountSatoshi < threshold {
+		return "", &DustError{Amount: amountSatoshi, Threshold: threshold}
+	}
+
+	changeOutput := wire.NewTxOut(0, changeSendToScript)
+	tx, _, err := c.fundTransaction(ctx, fromAddress, []*wire.TxOut{destOutput}, changeOutput, privKey, opts)
 	if err != nil {
-		log.Fatal(err)
 		return "", err
 	}
+	return encodeTx(tx)
+}
 
-	tx := wire.NewMsgTx(wire.TxVersion)
-	var sourceUTXOs []*UTXO
+func encodeTx(tx *wire.MsgTx) (string, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+	if err := tx.Serialize(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
 
-	for idx := range unspentTXOs {
-		hashStr := unspentTXOs[idx].Hash
+// keyInputWeight returns a function giving the weight of an input spending
+// pkScript with the key of pubKey and whether it has a witness.
+func keyInputWeight(pubKey *btcec.PublicKey) func(pkScript []byte) (int64, bool, error) {
+	uncompressedPubKeyHash := btcutil.Hash160(pubKey.SerializeUncompressed())
+	return func(pkScript []byte) (int64, bool, error) {
+		// 비압축 공개키의 P2PKH 주소는 scriptSig 가 32 bytes 더 크다.
+		compressed := txscript.GetScriptClass(pkScript) != txscript.PubKeyHashTy || !bytes.Equal(pkScript[3:23], uncompressedPubKeyHash)
+		return inputWeight(pkScript, compressed)
+	}
+}
 
-		sourceUTXOHash, err := chainhash.NewHashFromStr(hashStr)
+// keyInputVSize returns a function giving the vsize of an input spending
+// pkScript with the key of pubKey, rounded up.
+func keyInputVSize(pubKey *btcec.PublicKey) func(pkScript []byte) (int64, error) {
+	inputWeight := keyInputWeight(pubKey)
+	return func(pkScript []byte) (int64, error) {
+		weight, _, err := inputWeight(pkScript)
 		if err != nil {
-			log.Fatal(err)
+			return 0, err
 		}
-
-		sourceUTXOIndex := uint32(unspentTXOs[idx].TxIndex)
-		sourceUTXO := wire.NewOutPoint(sourceUTXOHash, sourceUTXOIndex)
-		sourceUTXOs = append(sourceUTXOs, unspentTXOs[idx])
-		sourceTxIn := wire.NewTxIn(sourceUTXO, nil, nil)
-
-		tx.AddTxIn(sourceTxIn)
-	}
-
-	// calculate fees
-	txByteSize := big.NewInt(int64(len(tx.TxIn)*180 + len(tx.TxOut)*34 + 10 + len(tx.TxIn)))
-	totalFee := new(big.Int).Mul(feeRate, txByteSize)
-	log.Printf("total fee: %s", totalFee)
-
-	// calculate the change
-	change := new(big.Int).Set(UTXOsAmount)
-	change = new(big.Int).Sub(change, amountToSend)
-	change = new(big.Int).Sub(change, totalFee)
-	if change.Cmp(big.NewInt(0)) == -1 {
-		log.Fatal(err)
+		return (weight + witnessScaleFactor - 1) / witnessScaleFactor, nil
 	}
+}
 
-	// create the tx outs
-	destAddress, err := btcutil.DecodeAddress(toAddress, chainParams)
-	if err != nil {
-		log.Fatal(err)
+// selectCoins runs SelectCoins and charges the witness overhead of the
+// selected UTXOs, see witnessOverhead. The overhead depends on the selection,
+// so the selection is repeated until the charged overhead covers it.
+func selectCoins(utxos []*UTXO, params CoinSelectionParams) (*CoinSelection, error) {
+	baseVSize := params.BaseVSize
+	var overhead int64
+	for {
+		params.BaseVSize = baseVSize + overhead
+		selection, err := SelectCoins(utxos, params)
+		if err != nil {
+			return nil, err
+		}
+		needed := witnessOverhead(selection.UTXOs)
+		if needed <= overhead {
+			return selection, nil
+		}
+		overhead = needed
 	}
+}
 
-	destScript, err := txscript.PayToAddrScript(destAddress)
-	if err != nil {
-		log.Fatal(err)
+// checkStandardWeight returns ErrTxTooLarge if the transaction of base, the
+// outputs, and selection would weigh more than a standard transaction.
+func checkStandardWeight(base TxSizeEstimator, selection *CoinSelection, changeOutput *wire.TxOut, inputWeight func(pkScript []byte) (int64, bool, error)) error {
+	for _, utxo := range selection.UTXOs {
+		weight, witness, err := inputWeight(utxo.PKScript)
+		if err != nil {
+			return fmt.Errorf("utxo %s:%d: %w", utxo.Hash, utxo.TxIndex, err)
+		}
+		base.addInput(weight, witness)
 	}
-
-	// tx out to send btc to user
-	destOutput := wire.NewTxOut(amountToSend.Int64(), destScript)
-	tx.AddTxOut(destOutput)
-
-	// our change address
-	changeSendToAddress, err := btcutil.DecodeAddress(fromAddress, chainParams)
-	if err != nil {
-		log.Fatal(err)
+	if selection.Change > 0 {
+		base.AddOutput(changeOutput.PkScript)
 	}
-
-	changeSendToScript, err := txscript.PayToAddrScript(changeSendToAddress)
-	if err != nil {
-		log.Fatal(err)
+	if weight := base.Weight(); weight > maxStandardTxWeight {
+		return fmt.Errorf("%w: %d inputs and %d outputs weigh %d WU, more than %d", ErrTxTooLarge, base.inputs, base.outputs, weight, maxStandardTxWeight)
 	}
+	return nil
+}
 
-	// tx out to send change back to us
-	changeOutput := wire.NewTxOut(change.Int64(), changeSendToScript)
-	tx.AddTxOut(changeOutput)
+// maxReserveAttempts is how many times coin selection is repeated when the
+// selected UTXOs were reserved by another transfer in the meantime.
+const maxReserveAttempts = 3
 
-	sourceAddress, err := btcutil.DecodeAddress(fromAddress, chainParams)
+// fundTransaction selects UTXOs of fromAddress for outputs and returns the
+// signed transaction and the selection. changeOutput is added after outputs
+// if the change is not dust. The UTXOs are reserved in c.Reservations if it
+// is set.
+func (c *Client) fundTransaction(ctx context.Context, fromAddress string, outputs []*wire.TxOut, changeOutput *wire.TxOut, privKey []byte, opts TransferOptions) (*wire.MsgTx, *CoinSelection, error) {
+	unspentTXOs, err := c.ListUTXOs(ctx, fromAddress)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
 
-	sourcePkScript, err := txscript.PayToAddrScript(sourceAddress)
+	feeRate, err := c.GetCurrentFeeRate(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
 
 	pKey, _ := btcec.PrivKeyFromBytes(privKey)
+	vsize := keyInputVSize(pKey.PubKey())
 
-	for i := range sourceUTXOs {
-		outputFetcher := txscript.NewCannedPrevOutputFetcher(sourcePkScript, sourceUTXOs[i].Amount.Int64())
-		txSigHashes := txscript.NewTxSigHashes(tx, outputFetcher)
-		txWitness, err := txscript.WitnessSignature(tx, txSigHashes, i, sourceUTXOs[i].Amount.Int64(), sourcePkScript, txscript.SigHashAll, pKey, true)
-		if err != nil {
-			log.Fatalf("could not generate pubSig; err: %v", err)
+	inputVSizes := make(map[*UTXO]int64, len(unspentTXOs))
+	for _, utxo := range unspentTXOs {
+		if inputVSizes[utxo], err = vsize(utxo.PKScript); err != nil {
+			return nil, nil, fmt.Errorf("utxo %s:%d: %w", utxo.Hash, utxo.TxIndex, err)
 		}
-		tx.TxIn[i].Witness = txWitness
-
-		fmt.Printf("txWitness: %v\n", txWitness)
 	}
-
-	buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
-	tx.Serialize(buf)
-
-	fmt.Printf("Redeem Tx: %v\n", hex.EncodeToString(buf.Bytes()))
-
-	t := hex.EncodeToString(buf.Bytes())
-	return t, nil
-}
-
-func TransferCoin(fromAddress string, toAddress string, privKey []byte, amountSatoshi int64) (string, error) {
-	log.Printf("%s->%s, CreateTransferTransaction amountSatoshi: %d", fromAddress, toAddress, amountSatoshi)
-	signedHex, err := CreateTransferTransaction(fromAddress, toAddress, privKey, amountSatoshi)
+	changeSpendVSize, err := vsize(changeOutput.PkScript)
 	if err != nil {
-		log.Fatal(err)
-		return "", err
+		return nil, nil, err
 	}
-	log.Printf("%s->%s SendRawTransaction", fromAddress, toAddress)
-	txHash, err := SendRawTransaction(signedHex)
-	if err != nil {
-		log.Fatal(err)
-		return "", err
-	}
-
-	log.Printf("%s->%s txHash: %s", fromAddress, toAddress, txHash)
-	return txHash, nil
-}
-
-func marshalUTXOs(utxos []*UTXO, amount, feeRate *big.Int) ([]*UTXO, *big.Int, error) {
-	// same strategy as bitcoin core
-	// from: https://blog.lopp.net/the-challenges-of-optimizing-unspent-output-selection/
-	// 1. sort the UTXOs from smallest to largest amounts
-	sort.Slice(utxos, func(i, j int) bool {
-		return utxos[i].Amount.Cmp(utxos[j].Amount) == -1
-	})
 
-	// 2. search for exact match
-	for idx := range utxos {
-		exactTxSize := calculateTotalTxBytes(1, 2)
-		totalFee := new(big.Int).Mul(feeRate, big.NewInt(int64(exactTxSize)))
-		totalTxAmount := new(big.Int).Add(totalFee, amount)
-
-		switch utxos[idx].Amount.Cmp(totalTxAmount) {
-		case 0:
-			var resp []*UTXO
-			resp = append(resp, utxos[idx])
-			// TODO: store these in the DB to be sure they aren't being claimed??
-			return resp, sumUTXOs(resp), nil
-
-		case 1:
-			break
-		}
+	// input 을 뺀 크기. segwit 의 marker 와 flag 는 selectCoins 가 더한다.
+	var base TxSizeEstimator
+	var target int64
+	for _, output := range outputs {
+		base.AddOutput(output.PkScript)
+		target += output.Value
 	}
 
-	// 3. calculate the sum of all UTXOs smaller than amount
-	sumSmall := big.NewInt(0)
-	var sumSmallUTXOs []*UTXO
-	for idx := range utxos {
-		switch utxos[idx].Amount.Cmp(amount) {
-		case -1:
-			_ = sumSmall.Add(sumSmall, utxos[idx].Amount)
-			sumSmallUTXOs = append(sumSmallUTXOs, utxos[idx])
+	for attempt := 1; ; attempt++ {
+		reserved, err := c.reservedOutpoints()
+		if err != nil {
+			return nil, nil, err
+		}
+		required, candidates, excluded, err := opts.apply(unspentTXOs, reserved)
+		if err != nil {
+			return nil, nil, err
+		}
 
-		default:
-			break
+		selection, err := selectCoins(candidates, CoinSelectionParams{
+			Target:            target,
+			FeeRate:           feeRate,
+			BaseVSize:         base.VSize(),
+			InputVSize:        func(utxo *UTXO) int64 { return inputVSizes[utxo] },
+			ChangeOutputVSize: outputSize(changeOutput.PkScript),
+			ChangeSpendVSize:  changeSpendVSize,
+			MinChange:         dustThreshold(changeOutput),
+			Required:          required,
+			Rand:              c.Rand,
+		})
+		if errors.Is(err, ErrInsufficientFunds) && excluded > 0 {
+			return nil, nil, fmt.Errorf("%w, %d satoshis are excluded, frozen or reserved", err, excluded)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkStandardWeight(base, selection, changeOutput, keyInputWeight(pKey.PubKey())); err != nil {
+			return nil, nil, err
 		}
-	}
 
-	exactTxSize := calculateTotalTxBytes(len(sumSmallUTXOs), 2)
-	totalFee := new(big.Int).Mul(feeRate, big.NewInt(int64(exactTxSize)))
-	totalTxAmount := new(big.Int).Add(totalFee, amount)
-
-	log.Printf("exactTxSize: %d", totalTxAmount)
-	log.Printf("totalFee: %d", totalFee)
-	log.Printf("totalTxAmount: %d", totalTxAmount)
-
-	switch sumSmall.Cmp(totalTxAmount) {
-	case 0:
-		return sumSmallUTXOs, sumUTXOs(sumSmallUTXOs), nil
-
-	case -1:
-		for idx := range utxos {
-			exactTxSize := calculateTotalTxBytes(1, 2)
-			totalFee := new(big.Int).Mul(feeRate, big.NewInt(int64(exactTxSize)))
-			totalTxAmount := new(big.Int).Add(totalFee, amount)
-			if utxos[idx].Amount.Cmp(totalTxAmount) == 1 {
-				var resp []*UTXO
-				resp = append(resp, utxos[idx])
-				return resp, sumUTXOs(resp), nil
+		tx := wire.NewMsgTx(wire.TxVersion)
+		var outpoints []wire.OutPoint
+		for _, utxo := range selection.UTXOs {
+			sourceUTXO, err := utxo.outpoint()
+			if err != nil {
+				return nil, nil, err
 			}
+			outpoints = append(outpoints, sourceUTXO)
+			txIn := wire.NewTxIn(&sourceUTXO, nil, nil)
+			txIn.Sequence = RBFSequence
+			tx.AddTxIn(txIn)
 		}
 
-		// should reach here if not enought UXOs
-		log.Fatal("not enough UTXOs to meet target amount")
+		for _, output := range outputs {
+			tx.AddTxOut(output)
+		}
 
-	case 1:
-		return roundRobinSelectUTXOs(sumSmallUTXOs, amount, feeRate)
+		// tx out to send change back to us
+		if selection.Change > 0 {
+			change := *changeOutput
+			change.Value = selection.Change
+			tx.AddTxOut(&change)
+		}
 
-	default:
-		log.Fatal("unknown comparison")
-	}
+		if err := signTransaction(tx, selection.UTXOs, pKey); err != nil {
+			return nil, nil, err
+		}
 
-	return nil, nil, nil
+		if c.Reservations == nil {
+			return tx, selection, nil
+		}
+		err = c.Reservations.Reserve(tx.TxHash().String(), outpoints, c.reservationTTL())
+		if errors.Is(err, ErrUTXOReserved) && attempt < maxReserveAttempts {
+			// 다른 transfer 가 먼저 예약했다. 예약을 다시 읽어서 고른다.
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return tx, selection, nil
+	}
 }
 
-func roundRobinSelectUTXOs(utxos []*UTXO, amount, feeRate *big.Int) ([]*UTXO, *big.Int, error) {
-	var possibilities [][]*UTXO
-	lenInput := len(utxos)
-	log.Printf("round robin select; lenInput: %v", lenInput)
-	if lenInput == 0 {
-		log.Fatal("expected utxos size to be greater than 0")
+// signTransaction signs every input of tx with privKey. utxos[i] is the
+// output spent by tx.TxIn[i] and its PKScript decides how the input is signed.
+func signTransaction(tx *wire.MsgTx, utxos []*UTXO, privKey *btcec.PrivateKey) error {
+	if len(utxos) != len(tx.TxIn) {
+		return fmt.Errorf("got %d utxos for %d inputs", len(utxos), len(tx.TxIn))
 	}
 
-	for i := 0; i < 1000; i++ {
-		selectedIdxs := make(map[int]bool)
-		var sum *big.Int
-		var possibility []*UTXO
-		for {
-			for {
-				rand.Seed(time.Now().Unix())
-				tmp := 0
-				if lenInput > 1 {
-					tmp = rand.Intn(lenInput - 1)
-				}
-
-				if !selectedIdxs[tmp] {
-					selectedIdxs[tmp] = true
-					_ = sum.Add(sum, utxos[tmp].Amount)
-					possibility = append(possibility, utxos[tmp])
-
-					break
-				}
-			}
+	// taproot sighash 는 모든 input 의 금액과 script 를 필요로 한다.
+	prevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, utxo := range utxos {
+		prevOutFetcher.AddPrevOut(tx.TxIn[i].PreviousOutPoint, wire.NewTxOut(utxo.Amount.Int64(), utxo.PKScript))
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, prevOutFetcher)
+
+	for i, utxo := range utxos {
+		amount := utxo.Amount.Int64()
 
-			exactTxSize := calculateTotalTxBytes(len(possibility), 2)
-			totalFee := new(big.Int).Mul(feeRate, big.NewInt(int64(exactTxSize)))
-			totalTxAmount := new(big.Int).Add(totalFee, amount)
+		switch txscript.GetScriptClass(utxo.PKScript) {
+		case txscript.WitnessV1TaprootTy:
+			// BIP86 key path spend. TaprootWitnessSignature 가 script root 없이 키를 tweak 한다.
+			outputKey := txscript.ComputeTaprootKeyNoScript(privKey.PubKey())
+			if !bytes.Equal(utxo.PKScript[2:], schnorr.SerializePubKey(outputKey)) {
+				return fmt.Errorf("input %d: taproot output is not a key path output of this key", i)
+			}
+			txWitness, err := txscript.TaprootWitnessSignature(tx, sigHashes, i, amount, utxo.PKScript, txscript.SigHashDefault, privKey)
+			if err != nil {
+				return fmt.Errorf("input %d: could not generate taproot signature: %w", i, err)
+			}
+			tx.TxIn[i].Witness = txWitness
 
-			if sum.Cmp(totalTxAmount) == 0 {
-				return possibility, sum, nil
+		case txscript.ScriptHashTy:
+			// P2SH-P2WPKH. scriptSig 에는 redeem script(P2WPKH script) 만 넣고 서명은 witness 에 넣는다.
+			redeemScript, err := payToWitnessPubKeyHashScript(privKey.PubKey().SerializeCompressed())
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(utxo.PKScript[2:22], btcutil.Hash160(redeemScript)) {
+				return fmt.Errorf("input %d: p2sh output is not a nested segwit output of this key", i)
 			}
+			txWitness, err := txscript.WitnessSignature(tx, sigHashes, i, amount, redeemScript, txscript.SigHashAll, privKey, true)
+			if err != nil {
+				return fmt.Errorf("input %d: could not generate witness signature: %w", i, err)
+			}
+			sigScript, err := txscript.NewScriptBuilder().AddData(redeemScript).Script()
+			if err != nil {
+				return err
+			}
+			tx.TxIn[i].SignatureScript = sigScript
+			tx.TxIn[i].Witness = txWitness
 
-			if sum.Cmp(totalTxAmount) == 1 {
-				possibilities = append(possibilities, possibility)
-				break
+		case txscript.WitnessV0PubKeyHashTy:
+			if !bytes.Equal(utxo.PKScript[2:], btcutil.Hash160(privKey.PubKey().SerializeCompressed())) {
+				return fmt.Errorf("input %d: p2wpkh output is not an output of this key", i)
+			}
+			txWitness, err := txscript.WitnessSignature(tx, sigHashes, i, amount, utxo.PKScript, txscript.SigHashAll, privKey, true)
+			if err != nil {
+				return fmt.Errorf("input %d: could not generate witness signature: %w", i, err)
+			}
+			tx.TxIn[i].Witness = txWitness
+
+		case txscript.PubKeyHashTy:
+			// legacy P2PKH. 주소가 압축/비압축 공개키 중 어느 것으로 만들어졌는지 확인해서 같은 공개키를 넣어야 한다.
+			var compress bool
+			pubKeyHash := utxo.PKScript[3:23]
+			switch {
+			case bytes.Equal(pubKeyHash, btcutil.Hash160(privKey.PubKey().SerializeCompressed())):
+				compress = true
+			case bytes.Equal(pubKeyHash, btcutil.Hash160(privKey.PubKey().SerializeUncompressed())):
+				compress = false
+			default:
+				return fmt.Errorf("input %d: p2pkh output is not an output of this key", i)
 			}
+			sigScript, err := txscript.SignatureScript(tx, i, utxo.PKScript, txscript.SigHashAll, privKey, compress)
+			if err != nil {
+				return fmt.Errorf("input %d: could not generate signature script: %w", i, err)
+			}
+			tx.TxIn[i].SignatureScript = sigScript
+
+		default:
+			return fmt.Errorf("input %d: unsupported script type %s", i, txscript.GetScriptClass(utxo.PKScript))
 		}
 	}
 
-	if len(possibilities) < 1 {
-		return nil, nil, errors.New("no possible utxo combos")
+	return nil
+}
+
+func TransferCoin(ctx context.Context, fromAddress string, toAddress string, privKey []byte, amountSatoshi int64) (string, error) {
+	client, err := defaultClient()
+	if err != nil {
+		return "", err
 	}
+	return client.TransferCoin(ctx, fromAddress, toAddress, privKey, amountSatoshi)
+}
 
-	smallestLen := len(possibilities[0])
-	smallestIdx := 0
+func (c *Client) TransferCoin(ctx context.Context, fromAddress string, toAddress string, privKey []byte, amountSatoshi int64) (string, error) {
+	return c.TransferCoinWithOptions(ctx, fromAddress, toAddress, privKey, amountSatoshi, TransferOptions{})
+}
 
-	for idx := 1; idx < len(possibilities); idx++ {
-		l := len(possibilities[idx])
-		if l < smallestLen {
-			smallestLen = l
-			smallestIdx = idx
-		}
+// TransferCoinWithOptions is TransferCoin spending only the UTXOs opts allow.
+func (c *Client) TransferCoinWithOptions(ctx context.Context, fromAddress string, toAddress string, privKey []byte, amountSatoshi int64, opts TransferOptions) (string, error) {
+	log.Printf("%s->%s, CreateTransferTransaction amountSatoshi: %d", fromAddress, toAddress, amountSatoshi)
+	signedHex, err := c.CreateTransferTransactionWithOptions(ctx, fromAddress, toAddress, privKey, amountSatoshi, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to create transaction: %w", err)
 	}
+	log.Printf("%s->%s SendRawTransaction", fromAddress, toAddress)
+	txHash, err := c.SendRawTransaction(ctx, signedHex)
+	if err != nil {
+		c.releaseReservation(signedHex)
+		return "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+	c.markSpent(signedHex)
 
-	return possibilities[smallestIdx], sumUTXOs(possibilities[smallestIdx]), nil
+	log.Printf("%s->%s txHash: %s", fromAddress, toAddress, txHash)
+	return txHash, nil
 }
 
 func sumUTXOs(utxos []*UTXO) *big.Int {
@@ -318,45 +383,33 @@ func sumUTXOs(utxos []*UTXO) *big.Int {
 	return sum
 }
 
-// https://bitcoin.stackexchange.com/questions/1195/how-to-calculate-transaction-size-before-sending-legacy-non-segwit-p2pkh-p2sh
-func calculateTotalTxBytes(txInLength, txOutLength int) int {
-	return txInLength*180 + txOutLength*34 + 10 + txInLength
+// dustThreshold returns the smallest amount of output that is not dust at the
+// default dust relay fee of 3 sat/vB, i.e. 546 satoshis for P2PKH and 294 for
+// P2WPKH. It is the same as mempool.GetDustThreshold of btcd and Bitcoin Core.
+func dustThreshold(output *wire.TxOut) int64 {
+	// output 과 그것을 사용하는 input 의 크기. witness 는 1/4 로 계산한다.
+	totalSize := output.SerializeSize() + 41
+	if txscript.IsWitnessProgram(output.PkScript) {
+		totalSize += 107 / 4
+	} else {
+		totalSize += 107
+	}
+	return 3 * int64(totalSize)
 }
 
-// GetCurrentFeeRate gets the current fee in satoshis per kb
-func GetCurrentFeeRate() (*big.Int, error) {
-	fee, err := GetCurrentFee()
+// GetCurrentFeeRate gets the current fee rate of DefaultClient in sat/vB.
+func GetCurrentFeeRate(ctx context.Context) (float64, error) {
+	client, err := defaultClient()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	// convert to satoshis to bytes
-	// feeRate := big.NewInt(int64(msg.Result * 1.0E8))
-	// convert to satoshis to kb
-	feeRate := big.NewInt(int64(fee * 1.0e5))
-
-	return feeRate, nil
+	return client.GetCurrentFeeRate(ctx)
 }
 
-func GetUTXO(address string) ([]*UTXO, error) {
-	addressEndpoint, err := GetAddressEndpoint(address)
+func GetUTXO(ctx context.Context, address string) ([]*UTXO, error) {
+	client, err := defaultClient()
 	if err != nil {
-		log.Panic(err)
 		return nil, err
 	}
-	// filter spent false from TxRefs
-	var utxos []*UTXO
-	for _, txRef := range addressEndpoint.TxRefs {
-		if !txRef.Spent {
-			utxos = append(utxos, &UTXO{
-				Hash:      txRef.TxHash,
-				TxIndex:   txRef.TxOutputN,
-				Amount:    big.NewInt(int64(txRef.Value)),
-				Spendable: true,
-				PKScript:  nil,
-			})
-			log.Printf("UTXO address: %s, hash: %s, amount: %d", address, txRef.TxHash, txRef.Value)
-		}
-
-	}
-	return utxos, nil
+	return client.ListUTXOs(ctx, address)
 }
@@ -1,18 +1,23 @@
 package btcw
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
+	"math/big"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 )
 
 func TestAddressEndpoint(t *testing.T) {
 	address := "miruDdUTqQv9eXMPPwXL73b9iy4gv8KeuH"
-	result, err := GetAddressEndpoint(address)
+	result, err := GetAddressEndpoint(context.Background(), address)
 	if err != nil {
 		t.Error(err)
 	}
@@ -43,9 +48,9 @@ func TestCreateTransferTransaction(t *testing.T) {
 	wif, _ := btcutil.DecodeWIF(fromWifString)
 	fromAddress := "myQCR5hm5R6NWoKn4o5MSLGiLTrKdk2AbD"
 	toAddress := "mkgMpQSbsJMBffCbVMUk6KryCuFGAtnFo9"
-	var amountSatoshi int64 = 100
+	var amountSatoshi int64 = 1000
 
-	signedHex, err := CreateTransferTransaction(fromAddress, toAddress, wif.PrivKey.Serialize(), amountSatoshi)
+	signedHex, err := CreateTransferTransaction(context.Background(), fromAddress, toAddress, wif.PrivKey.Serialize(), amountSatoshi)
 	if err != nil {
 		t.Error(err)
 	}
@@ -86,7 +91,7 @@ func TestTransferCoinFailed(t *testing.T) {
 
 	privKeyBytes, _ := HexToBytes(privateKey)
 
-	txHash, err := TransferCoin(fromAddress, toAddress, privKeyBytes, amountSatoshi)
+	txHash, err := TransferCoin(context.Background(), fromAddress, toAddress, privKeyBytes, amountSatoshi)
 	if err != nil {
 		t.Error(err)
 	}
@@ -105,7 +110,7 @@ func TestTransferCoinLegacy(t *testing.T) {
 	toAddress := "mkgMpQSbsJMBffCbVMUk6KryCuFGAtnFo9"
 	var amountSatoshi int64 = 100
 
-	txHash, err := TransferCoin(fromAddress, toAddress, wif.PrivKey.Serialize(), amountSatoshi)
+	txHash, err := TransferCoin(context.Background(), fromAddress, toAddress, wif.PrivKey.Serialize(), amountSatoshi)
 	if err != nil {
 		t.Error(err)
 	}
@@ -124,7 +129,7 @@ func TestTransferCoinNativeSegwit(t *testing.T) {
 	toAddress := "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q"
 	var amountSatoshi int64 = 1000
 
-	txHash, err := TransferCoin(fromAddress, toAddress, wif.PrivKey.Serialize(), amountSatoshi)
+	txHash, err := TransferCoin(context.Background(), fromAddress, toAddress, wif.PrivKey.Serialize(), amountSatoshi)
 	if err != nil {
 		t.Error(err)
 	}
@@ -163,8 +168,164 @@ func TestCreateTransferTransactionABCWalletPrivateKey(t *testing.T) {
 	}
 
 	var amountSatoshi int64 = 1000
-	txHash, _ := CreateTransferTransaction(fromAddress, expectedAddress, testnetWif.PrivKey.Serialize(), amountSatoshi)
+	txHash, _ := CreateTransferTransaction(context.Background(), fromAddress, expectedAddress, testnetWif.PrivKey.Serialize(), amountSatoshi)
 	fmt.Printf("txHash : %s\n", txHash)
-	txIdHash, _ := SendRawTransaction(txHash)
+	txIdHash, _ := SendRawTransaction(context.Background(), txHash)
 	fmt.Printf("txIdHash : %s\n", txIdHash)
 }
+
+// newTestUTXO returns a UTXO paying amount to pkScript with a made up outpoint.
+func newTestUTXO(index int, amount int64, pkScript []byte) *UTXO {
+	hash := chainhash.DoubleHashH([]byte(fmt.Sprintf("test utxo %d", index)))
+	return &UTXO{
+		Hash:      hash.String(),
+		TxIndex:   index,
+		Amount:    big.NewInt(amount),
+		Spendable: true,
+		PKScript:  pkScript,
+	}
+}
+
+func newTestSpendTx(t *testing.T, utxos []*UTXO, toScript []byte, amount int64) *wire.MsgTx {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for _, utxo := range utxos {
+		hash, err := chainhash.NewHashFromStr(utxo.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, uint32(utxo.TxIndex)), nil, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(amount, toScript))
+	return tx
+}
+
+// verifyTransaction runs every input of tx through the script engine.
+func verifyTransaction(t *testing.T, tx *wire.MsgTx, utxos []*UTXO) {
+	t.Helper()
+	prevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, utxo := range utxos {
+		prevOutFetcher.AddPrevOut(tx.TxIn[i].PreviousOutPoint, wire.NewTxOut(utxo.Amount.Int64(), utxo.PKScript))
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, prevOutFetcher)
+	for i, utxo := range utxos {
+		vm, err := txscript.NewEngine(utxo.PKScript, tx, i, txscript.StandardVerifyFlags, nil, sigHashes, utxo.Amount.Int64(), prevOutFetcher)
+		if err != nil {
+			t.Fatalf("input %d: %v", i, err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Errorf("input %d (%s): %v", i, txscript.GetScriptClass(utxo.PKScript), err)
+		}
+	}
+}
+
+func addressScript(t *testing.T, address string, net *chaincfg.Params) []byte {
+	t.Helper()
+	decoded, err := btcutil.DecodeAddress(address, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := txscript.PayToAddrScript(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkScript
+}
+
+func TestSignTransactionTaproot(t *testing.T) {
+	// P2TR(key path) input 과 P2WPKH input 을 함께 서명한다.
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+
+	taprootAddress := wallet.GetTaprootAddress(net)
+	if taprootAddress[:4] != "tb1p" {
+		t.Fatalf("unexpected taproot address %s", taprootAddress)
+	}
+
+	utxos := []*UTXO{
+		newTestUTXO(0, 20000, addressScript(t, taprootAddress, net)),
+		newTestUTXO(1, 15000, addressScript(t, wallet.GetSegwitAddress(net), net)),
+	}
+	tx := newTestSpendTx(t, utxos, addressScript(t, "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q", net), 30000)
+
+	if err := signTransaction(tx, utxos, wallet.PrivateKey); err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.TxIn[0].Witness) != 1 || len(tx.TxIn[0].Witness[0]) != 64 {
+		t.Errorf("taproot key path witness should be a single 64 byte schnorr signature")
+	}
+	verifyTransaction(t, tx, utxos)
+
+	// 다른 키의 taproot output 은 서명하지 않는다.
+	other, _ := CreateNewWallet()
+	if err := signTransaction(tx, utxos, other.PrivateKey); err == nil {
+		t.Errorf("signing another key's taproot output should fail")
+	}
+	if err := signTransaction(tx, utxos[:1], wallet.PrivateKey); err == nil || err.Error() != "got 1 utxos for 2 inputs" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestSignTransactionNestedSegwit(t *testing.T) {
+	// P2SH-P2WPKH input 은 scriptSig 에 redeem script, witness 에 서명이 들어가야 한다.
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("b6d6b2f9db22882e1e5187bfdfdc4e790582381dbc1f79463b83af307d9c98e1")
+
+	nestedAddress := wallet.GetNestedSegwitAddress(net)
+	if nestedAddress[0] != '2' {
+		t.Fatalf("unexpected testnet nested segwit address %s", nestedAddress)
+	}
+	if mainnetAddress := wallet.GetNestedSegwitAddress(&chaincfg.MainNetParams); mainnetAddress[0] != '3' {
+		t.Fatalf("unexpected mainnet nested segwit address %s", mainnetAddress)
+	}
+
+	utxos := []*UTXO{
+		newTestUTXO(0, 12000, addressScript(t, nestedAddress, net)),
+		newTestUTXO(1, 15000, addressScript(t, wallet.GetSegwitAddress(net), net)),
+		newTestUTXO(2, 9000, addressScript(t, wallet.GetTaprootAddress(net), net)),
+	}
+	tx := newTestSpendTx(t, utxos, addressScript(t, "tb1qf9k7gahvkcngazw3hwaclh6dqmc0g38ke3295q", net), 30000)
+
+	if err := signTransaction(tx, utxos, wallet.PrivateKey); err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.TxIn[0].SignatureScript) != 23 || len(tx.TxIn[0].Witness) != 2 {
+		t.Errorf("nested segwit input should have a 23 byte scriptSig and a 2 item witness")
+	}
+	if len(tx.TxIn[1].SignatureScript) != 0 {
+		t.Errorf("native segwit input should have an empty scriptSig")
+	}
+	verifyTransaction(t, tx, utxos)
+}
+
+func TestSignTransactionLegacy(t *testing.T) {
+	// legacy(압축/비압축) input 과 segwit input 이 섞인 transaction 을 서명한다.
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725")
+	compressedAddress := wallet.GetLegacyAddress(BITCOIN_TESTNET_VERSION)
+	uncompressedAddress, _ := GetLegacyAddressFromPubKeyBytes(wallet.SerializePubKeyUncompressed(), net)
+
+	utxos := []*UTXO{
+		newTestUTXO(0, 10000, addressScript(t, compressedAddress, net)),
+		newTestUTXO(1, 11000, addressScript(t, uncompressedAddress, net)),
+		newTestUTXO(2, 12000, addressScript(t, wallet.GetSegwitAddress(net), net)),
+		newTestUTXO(3, 13000, addressScript(t, wallet.GetNestedSegwitAddress(net), net)),
+		newTestUTXO(4, 14000, addressScript(t, wallet.GetTaprootAddress(net), net)),
+	}
+	tx := newTestSpendTx(t, utxos, addressScript(t, "mkgMpQSbsJMBffCbVMUk6KryCuFGAtnFo9", net), 55000)
+
+	if err := signTransaction(tx, utxos, wallet.PrivateKey); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if len(tx.TxIn[i].Witness) != 0 || len(tx.TxIn[i].SignatureScript) == 0 {
+			t.Errorf("legacy input %d should only have a scriptSig", i)
+		}
+	}
+	verifyTransaction(t, tx, utxos)
+
+	other, _ := CreateNewWallet()
+	otherTx := newTestSpendTx(t, utxos[:1], addressScript(t, "mkgMpQSbsJMBffCbVMUk6KryCuFGAtnFo9", net), 9000)
+	if err := signTransaction(otherTx, utxos[:1], other.PrivateKey); err == nil {
+		t.Errorf("signing another key's p2pkh output should fail")
+	}
+}
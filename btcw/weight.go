@@ -0,0 +1,179 @@
+package btcw
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MinRelayFeeRate is the default minimum relay fee of bitcoind in sat/vB.
+// Transactions paying less are not relayed.
+const MinRelayFeeRate = 1.0
+
+// maxStandardTxWeight is the largest weight of a transaction bitcoind relays.
+const maxStandardTxWeight = 400000
+
+// Sizes of the parts of a transaction in bytes. A DER signature with the
+// sighash flag is at most 72 bytes, a schnorr signature with SIGHASH_DEFAULT
+// 64 bytes.
+// https://bitcoinops.org/en/tools/calc-size/
+const (
+	witnessScaleFactor = 4
+	txOverheadSize     = 4 + 4 // version, lock time
+	// witnessHeaderWeight is the segwit marker and flag.
+	witnessHeaderWeight = 2
+	// emptyWitnessWeight is the witness of an input without witness in a
+	// segwit transaction.
+	emptyWitnessWeight = 1
+
+	// txInBaseSize is the outpoint, the sequence and the length of the
+	// signature script.
+	txInBaseSize                   = 32 + 4 + 4 + 1
+	p2pkhSigScriptSize             = 1 + 72 + 1 + 33
+	p2pkhUncompressedSigScriptSize = 1 + 72 + 1 + 65
+	p2shP2WPKHSigScriptSize        = 1 + 22
+	p2wpkhWitnessSize              = 1 + 1 + 72 + 1 + 33
+	p2trKeyPathWitnessSize         = 1 + 1 + 64
+	txOutBaseSize                  = 8 // value
+)
+
+// TxSizeEstimator estimates the weight and vsize of a signed transaction
+// before it is signed.
+type TxSizeEstimator struct {
+	inputs  int
+	outputs int
+	// weight is the weight of the inputs and outputs.
+	weight int64
+	// legacyInputs are the inputs without witness. They need an empty
+	// witness if any input has one.
+	legacyInputs int
+	witness      bool
+}
+
+// inputWeight returns the weight of an input spending pkScript. P2PKH inputs
+// use a compressed public key unless compressed is false.
+func inputWeight(pkScript []byte, compressed bool) (weight int64, witness bool, err error) {
+	switch class := txscript.GetScriptClass(pkScript); class {
+	case txscript.PubKeyHashTy:
+		return p2pkhInputWeight(compressed), false, nil
+	case txscript.ScriptHashTy:
+		// P2SH 는 P2SH-P2WPKH 만 서명할 수 있다.
+		return witnessScaleFactor*(txInBaseSize+p2shP2WPKHSigScriptSize) + p2wpkhWitnessSize, true, nil
+	case txscript.WitnessV0PubKeyHashTy:
+		return witnessScaleFactor*txInBaseSize + p2wpkhWitnessSize, true, nil
+	case txscript.WitnessV1TaprootTy:
+		return witnessScaleFactor*txInBaseSize + p2trKeyPathWitnessSize, true, nil
+	default:
+		return 0, false, fmt.Errorf("can not estimate the size of a %s input", class)
+	}
+}
+
+func p2pkhInputWeight(compressed bool) int64 {
+	if compressed {
+		return witnessScaleFactor * (txInBaseSize + p2pkhSigScriptSize)
+	}
+	return witnessScaleFactor * (txInBaseSize + p2pkhUncompressedSigScriptSize)
+}
+
+// inputVSize returns the vsize of an input spending pkScript, rounded up.
+func inputVSize(pkScript []byte, compressed bool) (int64, error) {
+	weight, _, err := inputWeight(pkScript, compressed)
+	if err != nil {
+		return 0, err
+	}
+	return (weight + witnessScaleFactor - 1) / witnessScaleFactor, nil
+}
+
+// witnessOverhead returns the vsize of the segwit marker and flag and of the
+// empty witnesses of the legacy inputs of a transaction spending utxos. It is
+// 0 if no input has a witness.
+func witnessOverhead(utxos []*UTXO) int64 {
+	var witness bool
+	var legacyInputs int64
+	for _, utxo := range utxos {
+		if txscript.IsWitnessProgram(utxo.PKScript) || txscript.IsPayToScriptHash(utxo.PKScript) {
+			witness = true
+		} else {
+			legacyInputs++
+		}
+	}
+	if !witness {
+		return 0
+	}
+	return (witnessHeaderWeight + legacyInputs*emptyWitnessWeight + witnessScaleFactor - 1) / witnessScaleFactor
+}
+
+// txVSize returns the virtual size of a signed transaction in vbytes.
+func txVSize(tx *wire.MsgTx) int64 {
+	weight := int64(tx.SerializeSizeStripped()*(witnessScaleFactor-1) + tx.SerializeSize())
+	return (weight + witnessScaleFactor - 1) / witnessScaleFactor
+}
+
+// outputSize returns the size of an output paying to pkScript.
+func outputSize(pkScript []byte) int64 {
+	return int64(txOutBaseSize + wire.VarIntSerializeSize(uint64(len(pkScript))) + len(pkScript))
+}
+
+// AddInput adds an input spending pkScript, which is P2PKH, P2SH-P2WPKH,
+// P2WPKH or a P2TR key path spend. P2PKH inputs are assumed to use a
+// compressed public key, see AddP2PKHInput.
+func (e *TxSizeEstimator) AddInput(pkScript []byte) error {
+	weight, witness, err := inputWeight(pkScript, true)
+	if err != nil {
+		return err
+	}
+	e.addInput(weight, witness)
+	return nil
+}
+
+// AddP2PKHInput adds a P2PKH input signed with a compressed or uncompressed
+// public key.
+func (e *TxSizeEstimator) AddP2PKHInput(compressed bool) {
+	e.addInput(p2pkhInputWeight(compressed), false)
+}
+
+// AddP2WSHInput adds a P2WSH input. witnessSize is the size of the witness
+// stack without the witness script, including the item count, and scriptSize
+// the size of the witness script.
+func (e *TxSizeEstimator) AddP2WSHInput(witnessSize, scriptSize int) {
+	witness := witnessSize + wire.VarIntSerializeSize(uint64(scriptSize)) + scriptSize
+	e.addInput(witnessScaleFactor*txInBaseSize+int64(witness), true)
+}
+
+func (e *TxSizeEstimator) addInput(weight int64, witness bool) {
+	e.inputs++
+	e.weight += weight
+	if witness {
+		e.witness = true
+	} else {
+		e.legacyInputs++
+	}
+}
+
+// AddOutput adds an output paying to pkScript.
+func (e *TxSizeEstimator) AddOutput(pkScript []byte) {
+	e.outputs++
+	e.weight += witnessScaleFactor * outputSize(pkScript)
+}
+
+// Weight returns the weight of the transaction in weight units.
+func (e *TxSizeEstimator) Weight() int64 {
+	weight := witnessScaleFactor*int64(txOverheadSize+wire.VarIntSerializeSize(uint64(e.inputs))+wire.VarIntSerializeSize(uint64(e.outputs))) + e.weight
+	if e.witness {
+		weight += witnessHeaderWeight + int64(e.legacyInputs)*emptyWitnessWeight
+	}
+	return weight
+}
+
+// VSize returns the virtual size of the transaction in vbytes.
+func (e *TxSizeEstimator) VSize() int64 {
+	return (e.Weight() + witnessScaleFactor - 1) / witnessScaleFactor
+}
+
+// Fee returns the fee of the transaction at feeRate sat/vB, at least the
+// minimum relay fee.
+func (e *TxSizeEstimator) Fee(feeRate float64) int64 {
+	return feeForVSize(math.Max(feeRate, MinRelayFeeRate), e.VSize())
+}
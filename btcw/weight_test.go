@@ -0,0 +1,106 @@
+package btcw
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func txWeight(tx *wire.MsgTx) int64 {
+	return int64(tx.SerializeSizeStripped()*(witnessScaleFactor-1) + tx.SerializeSize())
+}
+
+func TestTxSizeEstimator(t *testing.T) {
+	net := &chaincfg.TestNet3Params
+	wallet, _ := GetWalletFromPrivateKeyString("18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725")
+	uncompressedAddress, _ := GetLegacyAddressFromPubKeyBytes(wallet.SerializePubKeyUncompressed(), net)
+	compressedScript := addressScript(t, wallet.GetLegacyAddress(BITCOIN_TESTNET_VERSION), net)
+	uncompressedScript := addressScript(t, uncompressedAddress, net)
+	nestedScript := addressScript(t, wallet.GetNestedSegwitAddress(net), net)
+	segwitScript := addressScript(t, wallet.GetSegwitAddress(net), net)
+	taprootScript := addressScript(t, wallet.GetTaprootAddress(net), net)
+
+	tests := []struct {
+		name    string
+		scripts [][]byte
+		// vsize 는 input 1 개, output 1 개(P2WPKH, 31 vB) 일 때.
+		// segwit 이면 기본 10 vB 에 marker 와 flag 0.5 vB 가 더해지고 올림한다.
+		vsize int64
+	}{
+		{"p2pkh", [][]byte{compressedScript}, 10 + 148 + 31},
+		{"p2pkh uncompressed", [][]byte{uncompressedScript}, 10 + 180 + 31},
+		{"p2sh-p2wpkh", [][]byte{nestedScript}, 133}, // 10.5 + 91 + 31
+		{"p2wpkh", [][]byte{segwitScript}, 110},      // 10.5 + 68 + 31
+		{"p2tr", [][]byte{taprootScript}, 99},        // 10.5 + 57.5 + 31
+		{"mixed", [][]byte{compressedScript, uncompressedScript, nestedScript, segwitScript, taprootScript}, 0},
+	}
+	for _, test := range tests {
+		var utxos []*UTXO
+		var estimator TxSizeEstimator
+		for i, pkScript := range test.scripts {
+			utxos = append(utxos, newTestUTXO(i, 10000, pkScript))
+			if bytes.Equal(pkScript, uncompressedScript) {
+				estimator.AddP2PKHInput(false)
+			} else if err := estimator.AddInput(pkScript); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tx := newTestSpendTx(t, utxos, segwitScript, 5000)
+		estimator.AddOutput(segwitScript)
+		if err := signTransaction(tx, utxos, wallet.PrivateKey); err != nil {
+			t.Fatal(err)
+		}
+
+		// 서명 길이에 따라 input 마다 최대 1 byte 작을 수 있다.
+		weight, estimated := txWeight(tx), estimator.Weight()
+		if estimated < weight || estimated > weight+int64(len(utxos))*witnessScaleFactor {
+			t.Errorf("%s: estimated weight %d, actual %d", test.name, estimated, weight)
+		}
+		if test.vsize != 0 && estimator.VSize() != test.vsize {
+			t.Errorf("%s: unexpected vsize %d", test.name, estimator.VSize())
+		}
+	}
+}
+
+func TestTxSizeEstimatorP2WSH(t *testing.T) {
+	// 2-of-3 multisig: OP_0, 서명 2 개, 105 bytes witness script
+	witnessScript := make([]byte, 105)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, wire.TxWitness{nil, make([]byte, 72), make([]byte, 72), witnessScript}))
+	tx.AddTxOut(wire.NewTxOut(1000, make([]byte, 34)))
+
+	var estimator TxSizeEstimator
+	estimator.AddP2WSHInput(1+1+2*(1+72), len(witnessScript))
+	estimator.AddOutput(make([]byte, 34))
+	if weight := txWeight(tx); estimator.Weight() != weight {
+		t.Errorf("estimated weight %d, actual %d", estimator.Weight(), weight)
+	}
+	if _, err := inputVSize(make([]byte, 34), true); err == nil {
+		t.Errorf("estimating an unknown script should fail")
+	}
+}
+
+func TestTxSizeEstimatorFee(t *testing.T) {
+	var estimator TxSizeEstimator
+	estimator.AddP2PKHInput(true)
+	estimator.AddOutput(make([]byte, 25))
+	if vsize := estimator.VSize(); vsize != 192 {
+		t.Fatalf("unexpected vsize %d", vsize)
+	}
+	// 최소 relay fee 보다 적게 내지 않는다.
+	if fee := estimator.Fee(0.5); fee != 192 {
+		t.Errorf("unexpected fee %d", fee)
+	}
+	if fee := estimator.Fee(2.5); fee != 480 {
+		t.Errorf("unexpected fee %d", fee)
+	}
+
+	backend := newFakeBackend()
+	backend.feeRate = 0.2
+	if feeRate, err := NewClient(backend, &chaincfg.TestNet3Params).GetCurrentFeeRate(context.Background()); err != nil || feeRate != MinRelayFeeRate {
+		t.Errorf("unexpected fee rate %v %v", feeRate, err)
+	}
+}
@@ -2,12 +2,18 @@ module github.com/ricepotato/hello-go-bitcoin
 
 go 1.19
 
+require (
+	github.com/btcsuite/btcd v0.24.2-beta.rc1
+	github.com/btcsuite/btcd/btcec/v2 v2.3.3
+	github.com/btcsuite/btcd/btcutil v1.1.5
+	github.com/btcsuite/btcd/chaincfg/chainhash v1.1.0
+	go.etcd.io/bbolt v1.3.7
+	golang.org/x/crypto v0.16.0
+	golang.org/x/text v0.14.0
+)
+
 require (
 	github.com/aead/siphash v1.0.1 // indirect
-	github.com/btcsuite/btcd v0.24.2-beta.rc1 // indirect
-	github.com/btcsuite/btcd/btcec/v2 v2.3.3 // indirect
-	github.com/btcsuite/btcd/btcutil v1.1.5 // indirect
-	github.com/btcsuite/btcd/chaincfg/chainhash v1.1.0 // indirect
 	github.com/btcsuite/btclog v0.0.0-20170628155309-84c8d2346e9f // indirect
 	github.com/btcsuite/btcwallet v0.16.9 // indirect
 	github.com/btcsuite/btcwallet/walletdb v1.4.2 // indirect
@@ -28,7 +34,6 @@ require (
 	github.com/lightningnetwork/lnd/fn v1.1.0 // indirect
 	github.com/lightningnetwork/lnd/tlv v1.2.6 // indirect
 	github.com/syndtr/goleveldb v1.0.1-0.20210819022825-2ae1ddf74ef7 // indirect
-	golang.org/x/crypto v0.16.0 // indirect
 	golang.org/x/exp v0.0.0-20240613232115-7f521ea00fb8 // indirect
 	golang.org/x/sys v0.21.0 // indirect
 )
@@ -14,12 +14,20 @@ func main() {
 	address1PrivKey := "18e14a7b6a307f426a94f8114701e7c8e774e7f9a47e2c2035db29a206321725"
 	address2PrivKey := "d010d7a9b9a57f30e38a700fb5e2e367531f950e7a548939d4cfc8d5efc867b8"
 
-	wallet1 := btcw.GetWalletFromPrivateKeyString(address1PrivKey)
-	wallet2 := btcw.GetWalletFromPrivateKeyString(address2PrivKey)
-
-	// miruDdUTqQv9eXMPPwXL73b9iy4gv8KeuH
+	wallet1, err := btcw.GetWalletFromPrivateKeyString(address1PrivKey)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	wallet2, err := btcw.GetWalletFromPrivateKeyString(address2PrivKey)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// n3svudhm7bt6j3nTT9uu1A57Cs9pKK3iXW
 	wallet1TestnetAddress := wallet1.GetLegacyAddress(btcw.BITCOIN_TESTNET_VERSION)
-	// mshprpAi3YVjsiLQNNBP1z535K1YK6NLfa
+	// mz3U3hCnSf87JziYguiQzHYeKcce9CUXEB
 	wallet2TestnetAddress := wallet2.GetLegacyAddress(btcw.BITCOIN_TESTNET_VERSION)
 
 	fmt.Printf("Your bitcoin testnet address : %s\n", wallet1TestnetAddress)